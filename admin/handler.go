@@ -0,0 +1,246 @@
+// Package admin provides HTTP handler for reading and mutating stateholder entries.
+//
+// Handler serves following requests relative to its root:
+//
+//	GET  /keys            list entries
+//	GET  /keys/{key}      get entry
+//	PUT  /keys/{key}      set entry value given in request body
+//	POST /keys/{key}/inc  increment integer entry by delta given in request body (1 by default)
+//	POST /snapshot        get snapshot
+//
+// Integer values are decimal numbers, byte arrays are hexadecimal strings.
+package admin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alexeymaximov/stateholder"
+	"github.com/alexeymaximov/syspack"
+)
+
+// Maximum request body size.
+const maxBodySize = 1 << 20
+
+// Handler options.
+type Options struct {
+	// Reject mutating requests.
+	ReadOnly bool
+}
+
+type Handler struct {
+	// Handler.
+
+	// Stateholder.
+	sh *stateholder.Stateholder
+
+	// Options.
+	options Options
+}
+
+// Make new handler.
+func NewHandler(sh *stateholder.Stateholder, options *Options) *Handler {
+	handler := &Handler{sh: sh}
+	if options != nil {
+		handler.options = *options
+	}
+	return handler
+}
+
+// Entry representation.
+type entry struct {
+	Key    string          `json:"key"`
+	Kind   string          `json:"kind"`
+	Size   uint16          `json:"size"`
+	Offset syspack.Offset  `json:"offset"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// Serve request.
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/keys":
+		if !handler.allow(w, r, http.MethodGet) {
+			return
+		}
+		handler.list(w)
+	case path == "/snapshot":
+		if !handler.allow(w, r, http.MethodPost) {
+			return
+		}
+		handler.snapshot(w)
+	case strings.HasPrefix(path, "/keys/") && strings.HasSuffix(path, "/inc") && r.Method == http.MethodPost:
+		if handler.options.ReadOnly {
+			http.Error(w, "read-only", http.StatusForbidden)
+			return
+		}
+		handler.inc(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/keys/"), "/inc"))
+	case strings.HasPrefix(path, "/keys/"):
+		key := strings.TrimPrefix(path, "/keys/")
+		if !handler.allow(w, r, http.MethodGet, http.MethodPut) {
+			return
+		}
+		if r.Method == http.MethodPut {
+			if handler.options.ReadOnly {
+				http.Error(w, "read-only", http.StatusForbidden)
+				return
+			}
+			handler.set(w, r, key)
+			return
+		}
+		handler.get(w, key)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Check request method.
+func (handler *Handler) allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// Write error.
+func (handler *Handler) error(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err.(type) {
+	case *stateholder.ErrorUndefined:
+		status = http.StatusNotFound
+	case *stateholder.ErrorIncompatibleKind, *stateholder.ErrorIncompatibleSize, *stateholder.ErrorInvalidValue:
+		status = http.StatusBadRequest
+	case *stateholder.ErrorClosed, *stateholder.ErrorDetached:
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// Write JSON.
+func (handler *Handler) json(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// Read request body.
+func (handler *Handler) body(r *http.Request) (string, error) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Describe entry.
+func (handler *Handler) entry(key string, withValue bool) (*entry, error) {
+	info, err := handler.sh.Describe(key)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{Key: key, Kind: info.Kind.String(), Size: info.Size, Offset: info.Offset}
+	if withValue {
+		value, err := handler.sh.GetString(key)
+		if err != nil {
+			return nil, err
+		}
+		if info.Kind == stateholder.KindBytes {
+			value = strconv.Quote(value)
+		}
+		e.Value = json.RawMessage(value)
+	}
+	return e, nil
+}
+
+// List entries.
+func (handler *Handler) list(w http.ResponseWriter) {
+	entries := make([]*entry, 0)
+	for _, key := range handler.sh.Keys() {
+		e, err := handler.entry(key, false)
+		if err != nil {
+			handler.error(w, err)
+			return
+		}
+		entries = append(entries, e)
+	}
+	handler.json(w, entries)
+}
+
+// Get entry.
+func (handler *Handler) get(w http.ResponseWriter, key string) {
+	e, err := handler.entry(key, true)
+	if err != nil {
+		handler.error(w, err)
+		return
+	}
+	handler.json(w, e)
+}
+
+// Set entry value.
+func (handler *Handler) set(w http.ResponseWriter, r *http.Request, key string) {
+	value, err := handler.body(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := handler.sh.SetString(key, value); err != nil {
+		handler.error(w, err)
+		return
+	}
+	handler.get(w, key)
+}
+
+// Increment integer entry.
+func (handler *Handler) inc(w http.ResponseWriter, r *http.Request, key string) {
+	body, err := handler.body(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, err := handler.sh.Describe(key)
+	if err != nil {
+		handler.error(w, err)
+		return
+	}
+	if info.Kind == stateholder.KindBytes {
+		handler.error(w, &stateholder.ErrorIncompatibleKind{Key: key, Kind: info.Kind, GivenKind: stateholder.KindUint64})
+		return
+	}
+	delta := uint64(1)
+	if body != "" {
+		if delta, err = strconv.ParseUint(body, 10, 8*int(info.Size)); err != nil {
+			handler.error(w, &stateholder.ErrorInvalidValue{Key: key, Value: body})
+			return
+		}
+	}
+	switch info.Kind {
+	case stateholder.KindByte:
+		_, _, err = handler.sh.IncByte(key, byte(delta))
+	case stateholder.KindUint16:
+		_, _, err = handler.sh.IncUint16(key, uint16(delta))
+	case stateholder.KindUint32:
+		_, _, err = handler.sh.IncUint32(key, uint32(delta))
+	case stateholder.KindUint64:
+		_, _, err = handler.sh.IncUint64(key, delta)
+	}
+	if err != nil {
+		handler.error(w, err)
+		return
+	}
+	handler.get(w, key)
+}
+
+// Write snapshot.
+func (handler *Handler) snapshot(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := handler.sh.Snapshot(w); err != nil {
+		handler.error(w, err)
+	}
+}
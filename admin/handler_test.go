@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+func testRequest(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	sh := stateholder.NewStateholder()
+	defer sh.Close()
+	sh.Define("bytes", 5)
+	sh.DefineUint64("uint64")
+	if _, err := sh.Attach(filepath.Join(t.TempDir(), "test-admin.mem"), nil); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(sh, nil)
+	if w := testRequest(handler, "GET", "/keys", ""); w.Code != http.StatusOK {
+		t.Fatalf("status must be a %d, %d found", http.StatusOK, w.Code)
+	} else if body := w.Body.String(); body != `[{"key":"bytes","kind":"byte array","size":5,"offset":8},{"key":"uint64","kind":"uint64","size":8,"offset":21}]`+"\n" {
+		t.Fatalf("unexpected body %s found", body)
+	}
+	if w := testRequest(handler, "PUT", "/keys/bytes", "48454c4c4f\n"); w.Code != http.StatusOK {
+		t.Fatalf("status must be a %d, %d found", http.StatusOK, w.Code)
+	} else if body := w.Body.String(); body != `{"key":"bytes","kind":"byte array","size":5,"offset":8,"value":"48454c4c4f"}`+"\n" {
+		t.Fatalf("unexpected body %s found", body)
+	}
+	if w := testRequest(handler, "POST", "/keys/uint64/inc", "1024"); w.Code != http.StatusOK {
+		t.Fatalf("status must be a %d, %d found", http.StatusOK, w.Code)
+	} else if value, err := sh.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != 1024 {
+		t.Fatalf("uint64 must be a %d, %d found", 1024, value)
+	}
+	if w := testRequest(handler, "GET", "/keys/byte", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("status must be a %d, %d found", http.StatusNotFound, w.Code)
+	}
+	if w := testRequest(handler, "PUT", "/keys/uint64", "-1"); w.Code != http.StatusBadRequest {
+		t.Fatalf("status must be a %d, %d found", http.StatusBadRequest, w.Code)
+	}
+	if w := testRequest(handler, "POST", "/snapshot", ""); w.Code != http.StatusOK {
+		t.Fatalf("status must be a %d, %d found", http.StatusOK, w.Code)
+	} else if size := w.Body.Len(); size != 20+int(sh.Size()) {
+		t.Fatalf("snapshot size must be a %d, %d found", 20+int(sh.Size()), size)
+	}
+	handler = NewHandler(sh, &Options{ReadOnly: true})
+	if w := testRequest(handler, "PUT", "/keys/uint64", "0"); w.Code != http.StatusForbidden {
+		t.Fatalf("status must be a %d, %d found", http.StatusForbidden, w.Code)
+	}
+}
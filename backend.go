@@ -0,0 +1,152 @@
+package stateholder
+
+import (
+	"io"
+	"os"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+// Storage backend.
+type Backend interface {
+	// Read data at offset.
+	ReadAt(buffer []byte, offset syspack.Offset) (int, error)
+
+	// Write data at offset.
+	WriteAt(buffer []byte, offset syspack.Offset) (int, error)
+
+	// Sync data.
+	Sync() error
+
+	// Close backend.
+	Close() error
+}
+
+// Storage backend with direct memory access.
+type DirectBackend interface {
+	Backend
+
+	// Get memory.
+	Bytes() []byte
+}
+
+// Storage backend which is able to sync data range.
+type RangeSyncer interface {
+	// Sync data range.
+	SyncRange(offset syspack.Offset, size syspack.Size) error
+}
+
+// Backend factory which opens backend for file region.
+// File remains open while it is attached, backend may use it but must not close it.
+type BackendFactory func(file *os.File, offset syspack.Offset, size syspack.Size) (Backend, error)
+
+type fileBackend struct {
+	// Plain file backend.
+
+	// File.
+	file *os.File
+
+	// Region offset.
+	offset syspack.Offset
+
+	// Region size.
+	size syspack.Size
+}
+
+// Open plain file backend which uses positional reads and writes.
+func FileBackend(file *os.File, offset syspack.Offset, size syspack.Size) (Backend, error) {
+	f, err := os.OpenFile(file.Name(), os.O_RDWR, 0)
+	if os.IsPermission(err) {
+		f, err = os.Open(file.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fileBackend{file: f, offset: offset, size: size}, nil
+}
+
+// Read data at offset.
+func (backend *fileBackend) ReadAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if syspack.Size(offset) >= backend.size {
+		return 0, io.EOF
+	}
+	if rest := backend.size - syspack.Size(offset); syspack.Size(len(buffer)) > rest {
+		buffer = buffer[:rest]
+	}
+	return backend.file.ReadAt(buffer, int64(backend.offset+offset))
+}
+
+// Write data at offset.
+func (backend *fileBackend) WriteAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if syspack.Size(offset) >= backend.size {
+		return 0, io.EOF
+	}
+	if rest := backend.size - syspack.Size(offset); syspack.Size(len(buffer)) > rest {
+		buffer = buffer[:rest]
+	}
+	return backend.file.WriteAt(buffer, int64(backend.offset+offset))
+}
+
+// Sync data.
+func (backend *fileBackend) Sync() error {
+	return backend.file.Sync()
+}
+
+// Close backend.
+func (backend *fileBackend) Close() error {
+	return backend.file.Close()
+}
+
+// Anonymous memory backend.
+type memoryBackend []byte
+
+// Make anonymous memory backend.
+func NewMemoryBackend(size syspack.Size) DirectBackend {
+	return make(memoryBackend, size)
+}
+
+// Open anonymous memory backend initialized with file region.
+// Data is never written back to file.
+func MemoryBackend(file *os.File, offset syspack.Offset, size syspack.Size) (Backend, error) {
+	backend := make(memoryBackend, size)
+	if _, err := file.ReadAt(backend, int64(offset)); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// Read data at offset.
+func (backend memoryBackend) ReadAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if syspack.Size(offset) >= syspack.Size(len(backend)) {
+		return 0, io.EOF
+	}
+	return copy(buffer, backend[offset:]), nil
+}
+
+// Write data at offset.
+func (backend memoryBackend) WriteAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if syspack.Size(offset) >= syspack.Size(len(backend)) {
+		return 0, io.EOF
+	}
+	return copy(backend[offset:], buffer), nil
+}
+
+// Sync data.
+func (backend memoryBackend) Sync() error {
+	return nil
+}
+
+// Sync data range.
+func (backend memoryBackend) SyncRange(offset syspack.Offset, size syspack.Size) error {
+	return nil
+}
+
+// Close backend.
+func (backend memoryBackend) Close() error {
+	return nil
+}
+
+// Get memory.
+func (backend memoryBackend) Bytes() []byte {
+	return backend
+}
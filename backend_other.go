@@ -0,0 +1,66 @@
+//go:build !darwin && !freebsd && !linux && !openbsd
+// +build !darwin,!freebsd,!linux,!openbsd
+
+package stateholder
+
+import (
+	"os"
+
+	"github.com/alexeymaximov/syspack"
+	"github.com/alexeymaximov/syspack/mmap"
+)
+
+type mmapBackend struct {
+	// Memory mapping backend.
+
+	// Mapping starting at the beginning of file.
+	mapping *mmap.Mapping
+
+	// Region offset within mapping.
+	offset syspack.Offset
+
+	// Whether file is mapped read-only.
+	readOnly bool
+}
+
+// Open memory mapping backend.
+// File is mapped from the beginning, so mapping offset is always page-aligned.
+// File opened read-only is mapped read-only.
+func MmapBackend(file *os.File, offset syspack.Offset, size syspack.Size) (Backend, error) {
+	mapping, err := mmap.NewMapping(file.Fd(), 0, syspack.Size(offset)+size, &mmap.Options{
+		Mode: mmap.ModeReadWrite,
+	})
+	if err == nil {
+		return &mmapBackend{mapping: mapping, offset: offset}, nil
+	}
+	mapping, roErr := mmap.NewMapping(file.Fd(), 0, syspack.Size(offset)+size, &mmap.Options{
+		Mode: mmap.ModeReadOnly,
+	})
+	if roErr != nil {
+		return nil, err
+	}
+	return &mmapBackend{mapping: mapping, offset: offset, readOnly: true}, nil
+}
+
+// Read data at offset.
+func (backend *mmapBackend) ReadAt(buffer []byte, offset syspack.Offset) (int, error) {
+	return backend.mapping.ReadAt(buffer, backend.offset+offset)
+}
+
+// Write data at offset.
+func (backend *mmapBackend) WriteAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if backend.readOnly {
+		return 0, &ErrorReadOnly{}
+	}
+	return backend.mapping.WriteAt(buffer, backend.offset+offset)
+}
+
+// Sync data.
+func (backend *mmapBackend) Sync() error {
+	return backend.mapping.Sync()
+}
+
+// Close backend.
+func (backend *mmapBackend) Close() error {
+	return backend.mapping.Close()
+}
@@ -0,0 +1,91 @@
+package stateholder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmapBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	for i := 0; i < os.Getpagesize(); i++ {
+		if err := stateholder.DefineByte(fmt.Sprintf("byte%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if ranges := stateholder.dirty.take(); len(ranges) != 1 {
+		t.Fatalf("dirty ranges must be a %d, %d found", 1, len(ranges))
+	}
+	if err := stateholder.Set("bytes", testBytes); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if ranges := stateholder.dirty.take(); len(ranges) != 0 {
+		t.Fatalf("dirty ranges must be a %d, %d found", 0, len(ranges))
+	}
+	if err := stateholder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stateholder = testStateholder()
+	defer stateholder.Close()
+	for i := 0; i < os.Getpagesize(); i++ {
+		if err := stateholder.DefineByte(fmt.Sprintf("byte%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stateholder.AttachWithOptions(path, &Options{Backend: FileBackend}); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+}
+
+func benchmarkHotCounters(b *testing.B, sync func(stateholder *Stateholder) error) {
+	stateholder := NewStateholder()
+	defer stateholder.Close()
+	for i := 0; i < 4096; i++ {
+		if err := stateholder.DefineUint64(fmt.Sprintf("counter%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := stateholder.Attach(filepath.Join(b.TempDir(), "test.mem"), nil); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range []string{"counter0", "counter1024", "counter2048", "counter4095"} {
+			if _, _, err := stateholder.IncUint64(key, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := sync(stateholder); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFullSync(b *testing.B) {
+	benchmarkHotCounters(b, func(stateholder *Stateholder) error {
+		stateholder.dirty.take()
+		return stateholder.backend.Sync()
+	})
+}
+
+func BenchmarkRangeSync(b *testing.B) {
+	benchmarkHotCounters(b, func(stateholder *Stateholder) error {
+		return stateholder.Sync()
+	})
+}
@@ -0,0 +1,108 @@
+//go:build darwin || freebsd || linux || openbsd
+// +build darwin freebsd linux openbsd
+
+package stateholder
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+type mmapBackend struct {
+	// Memory mapping backend.
+
+	// Mapped memory starting at page boundary.
+	memory []byte
+
+	// Region data.
+	data []byte
+
+	// Whether memory is mapped read-only.
+	readOnly bool
+}
+
+// Open memory mapping backend.
+// Mapping starts at page boundary preceding region and syncs dirty ranges only.
+// File opened read-only is mapped read-only.
+func MmapBackend(file *os.File, offset syspack.Offset, size syspack.Size) (Backend, error) {
+	page := syspack.Offset(os.Getpagesize())
+	aligned := offset / page * page
+	length := int(offset-aligned) + int(size)
+	readOnly := false
+	memory, err := syscall.Mmap(int(file.Fd()), int64(aligned), length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err == syscall.EACCES {
+		readOnly = true
+		memory, err = syscall.Mmap(int(file.Fd()), int64(aligned), length, syscall.PROT_READ, syscall.MAP_SHARED)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mmapBackend{memory: memory, data: memory[offset-aligned:], readOnly: readOnly}, nil
+}
+
+// Read data at offset.
+func (backend *mmapBackend) ReadAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if syspack.Size(offset) >= syspack.Size(len(backend.data)) {
+		return 0, io.EOF
+	}
+	return copy(buffer, backend.data[offset:]), nil
+}
+
+// Write data at offset.
+func (backend *mmapBackend) WriteAt(buffer []byte, offset syspack.Offset) (int, error) {
+	if backend.readOnly {
+		return 0, &ErrorReadOnly{}
+	}
+	if syspack.Size(offset) >= syspack.Size(len(backend.data)) {
+		return 0, io.EOF
+	}
+	return copy(backend.data[offset:], buffer), nil
+}
+
+// Get memory.
+func (backend *mmapBackend) Bytes() []byte {
+	return backend.data
+}
+
+// Sync memory.
+func msync(memory []byte) error {
+	if len(memory) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&memory[0])), uintptr(len(memory)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Sync data.
+func (backend *mmapBackend) Sync() error {
+	return msync(backend.memory)
+}
+
+// Sync data range.
+func (backend *mmapBackend) SyncRange(offset syspack.Offset, size syspack.Size) error {
+	page := syspack.Offset(os.Getpagesize())
+	delta := syspack.Offset(len(backend.memory) - len(backend.data))
+	start := (delta + offset) / page * page
+	end := delta + offset + syspack.Offset(size)
+	if end > syspack.Offset(len(backend.memory)) {
+		end = syspack.Offset(len(backend.memory))
+	}
+	if start >= end {
+		return nil
+	}
+	return msync(backend.memory[start:end])
+}
+
+// Close backend.
+func (backend *mmapBackend) Close() error {
+	err := syscall.Munmap(backend.memory)
+	backend.memory, backend.data = nil, nil
+	return err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+// Print header and entry table.
+func inspect(schemaPath string, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	sh, entries, info, err := open(args[0], schemaPath, true)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	seq, err := sh.Sequence()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "file:\t%s\n", args[0])
+	fmt.Fprintf(w, "size:\t%d bytes\n", info.Size)
+	layout := "single"
+	if info.DoubleBuffered {
+		layout = "double-buffered"
+	}
+	if info.Magic == "" {
+		fmt.Fprintln(w, "format:\tcustom")
+	} else {
+		fmt.Fprintf(w, "format:\t%s %s\n", info.Magic, info.Version)
+	}
+	fmt.Fprintf(w, "layout:\t%s\n", layout)
+	fmt.Fprintf(w, "signature:\t%d bytes\n", info.SignSize)
+	fmt.Fprintf(w, "header:\t%d bytes\n", info.HeaderSize)
+	fmt.Fprintf(w, "sequence:\t%d\n", seq)
+	fmt.Fprintf(w, "entries:\t%d\n", len(entries))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "KEY\tKIND\tSIZE\tOFFSET")
+	for _, entry := range entries {
+		entryInfo, err := sh.Describe(entry.Key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", entry.Key, entryInfo.Kind, entryInfo.Size, entryInfo.Offset)
+	}
+	return w.Flush()
+}
+
+// Print entry value.
+func get(schemaPath string, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	sh, _, _, err := open(args[0], schemaPath, true)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	value, err := sh.GetString(args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(output, value)
+	return nil
+}
+
+// Set entry value.
+func set(schemaPath string, args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+	sh, _, _, err := open(args[0], schemaPath, false)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	if err := sh.SetString(args[1], args[2]); err != nil {
+		return err
+	}
+	return sh.Sync()
+}
+
+// Increment integer entry.
+func inc(schemaPath string, args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errUsage
+	}
+	sh, _, _, err := open(args[0], schemaPath, false)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	key := args[1]
+	info, err := sh.Describe(key)
+	if err != nil {
+		return err
+	}
+	kind := info.Kind
+	if kind == stateholder.KindBytes {
+		return &stateholder.ErrorIncompatibleKind{Key: key, Kind: kind, GivenKind: stateholder.KindUint64}
+	}
+	delta := uint64(1)
+	if len(args) == 3 {
+		if delta, err = strconv.ParseUint(args[2], 10, 8*int(info.Size)); err != nil {
+			return &stateholder.ErrorInvalidValue{Key: key, Value: args[2]}
+		}
+	}
+	switch kind {
+	case stateholder.KindByte:
+		_, _, err = sh.IncByte(key, byte(delta))
+	case stateholder.KindUint16:
+		_, _, err = sh.IncUint16(key, uint16(delta))
+	case stateholder.KindUint32:
+		_, _, err = sh.IncUint32(key, uint32(delta))
+	case stateholder.KindUint64:
+		_, _, err = sh.IncUint64(key, delta)
+	}
+	if err != nil {
+		return err
+	}
+	if err := sh.Sync(); err != nil {
+		return err
+	}
+	return get(schemaPath, args[:2])
+}
+
+// Print all entries.
+func dump(schemaPath string, args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print entries as JSON object")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	sh, entries, _, err := open(flags.Arg(0), schemaPath, true)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	if *asJSON {
+		if err := sh.ExportJSON(output); err != nil {
+			return err
+		}
+		fmt.Fprintln(output)
+		return nil
+	}
+	w := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	for _, entry := range entries {
+		value, err := sh.GetString(entry.Key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\n", entry.Key, value)
+	}
+	return w.Flush()
+}
+
+// Verify file against schema.
+// Attach fails if signature or size of file does not match schema.
+func verify(schemaPath string, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	sh, _, _, err := open(args[0], schemaPath, true)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	fmt.Fprintln(output, "ok")
+	return nil
+}
+
+// Print entries which differ.
+// Second file may also be a snapshot.
+func diff(schemaPath string, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	a, entries, _, err := open(args[0], schemaPath, true)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := entries.stateholder()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	info, err := readFileInfo(args[1], schemaPath)
+	if err != nil {
+		return err
+	}
+	if err := attach(b, args[1], info, true); err != nil {
+		return err
+	}
+	changes, err := stateholder.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Fprintln(output, change)
+	}
+	return nil
+}
+
+// Upgrade file of earlier format.
+// File with default signature is upgraded on writable attach.
+func migrate(schemaPath string, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	sh, _, info, err := open(args[0], schemaPath, false)
+	if err != nil {
+		return err
+	}
+	defer sh.Close()
+	if customSign != "" {
+		fmt.Fprintln(output, "ok")
+		return nil
+	}
+	upgraded, err := stateholder.ReadFileInfo(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "%s %s -> %s %s\n", info.Magic, info.Version, upgraded.Magic, upgraded.Version)
+	return nil
+}
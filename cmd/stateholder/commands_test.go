@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+// Run command and return its output.
+func run(command func(string, []string) error, schemaPath string, args ...string) (string, error) {
+	buffer := &bytes.Buffer{}
+	output = buffer
+	defer func() {
+		output = os.Stdout
+	}()
+	err := command(schemaPath, args)
+	return buffer.String(), err
+}
+
+func TestInspect(t *testing.T) {
+	filePath, schemaPath := testFile(t, &stateholder.Options{DoubleBuffered: true})
+	out, err := run(inspect, schemaPath, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"format:     MED 3.0.0", "layout:     double-buffered", "signature:  15 bytes", "header:     16 bytes", "retries  uint32      4     21"} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("output must contain %q, %q found", line, out)
+		}
+	}
+}
+
+func TestGetSetInc(t *testing.T) {
+	for _, options := range []*stateholder.Options{nil, {DoubleBuffered: true}} {
+		filePath, schemaPath := testFile(t, options)
+		if _, err := run(set, schemaPath, filePath, "retries", "41"); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := run(inc, schemaPath, filePath, "retries"); err != nil {
+			t.Fatal(err)
+		} else if out != "42\n" {
+			t.Fatalf("retries must be a %q, %q found", "42\n", out)
+		}
+		if out, err := run(inc, "", filePath, "1", "8"); err != nil {
+			t.Fatal(err)
+		} else if out != "50\n" {
+			t.Fatalf("retries must be a %q, %q found", "50\n", out)
+		}
+		if _, err := run(inc, schemaPath, filePath, "name"); err == nil {
+			t.Fatal("expected ErrorIncompatibleKind, nil error found")
+		} else if _, ok := err.(*stateholder.ErrorIncompatibleKind); !ok {
+			t.Fatalf("expected ErrorIncompatibleKind, [%v] error found", err)
+		}
+		if _, err := run(set, schemaPath, filePath, "name", "68656c6c6f"); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := run(get, schemaPath, filePath, "name"); err != nil {
+			t.Fatal(err)
+		} else if out != "68656c6c6f\n" {
+			t.Fatalf("name must be a %q, %q found", "68656c6c6f\n", out)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	filePath, schemaPath := testFile(t, nil)
+	if _, err := run(set, schemaPath, filePath, "retries", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run(dump, schemaPath, filePath); err != nil {
+		t.Fatal(err)
+	} else if out != "name     0000000000\nretries  3\nflag     0\n" {
+		t.Fatalf("unexpected dump %q", out)
+	}
+	if out, err := run(dump, schemaPath, "-json", filePath); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(out, `"retries":3`) {
+		t.Fatalf("unexpected dump %q", out)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	filePath, schemaPath := testFile(t, nil)
+	if out, err := run(verify, schemaPath, filePath); err != nil {
+		t.Fatal(err)
+	} else if out != "ok\n" {
+		t.Fatalf("verify must print %q, %q found", "ok\n", out)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filePath, info.Size()+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(verify, schemaPath, filePath); err == nil {
+		t.Fatal("expected ErrorBadFile, nil error found")
+	} else if _, ok := err.(*stateholder.ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	for _, options := range []*stateholder.Options{nil, {DoubleBuffered: true}} {
+		a, schemaPath := testFile(t, nil)
+		b, _ := testFile(t, options)
+		if _, err := run(set, schemaPath, b, "retries", "7"); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := run(diff, schemaPath, a, b); err != nil {
+			t.Fatal(err)
+		} else if out != "retries: 0 -> 7\n" {
+			t.Fatalf("unexpected diff %q", out)
+		}
+	}
+}
+
+func TestCustomSign(t *testing.T) {
+	defer func() {
+		customSign = ""
+		doubleBuffered = false
+	}()
+	for _, options := range []*stateholder.Options{{Sign: []byte("CUSTOM")}, {Sign: []byte("CUSTOM"), DoubleBuffered: true}} {
+		filePath, schemaPath := testFile(t, options)
+		customSign = "CUSTOM"
+		doubleBuffered = options.DoubleBuffered
+		if _, err := run(set, schemaPath, filePath, "retries", "5"); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := run(get, schemaPath, filePath, "retries"); err != nil {
+			t.Fatal(err)
+		} else if out != "5\n" {
+			t.Fatalf("retries must be a %q, %q found", "5\n", out)
+		}
+		if out, err := run(inspect, schemaPath, filePath); err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(out, "format:     custom") || !strings.Contains(out, "signature:  6 bytes") {
+			t.Fatalf("unexpected inspect %q", out)
+		}
+		if _, err := run(get, "", filePath, "1"); err != errUsage {
+			t.Fatalf("expected errUsage, [%v] error found", err)
+		}
+		customSign = ""
+		if _, err := run(get, schemaPath, filePath, "retries"); err == nil {
+			t.Fatal("expected ErrorBadFile, nil error found")
+		} else if _, ok := err.(*stateholder.ErrorBadFile); !ok {
+			t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	filePath, schemaPath := testFile(t, nil)
+	info, err := stateholder.ReadFileInfo(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Odd sequence number is repaired on writable attach only.
+	if _, err := file.WriteAt([]byte{1}, int64(info.SignSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{filePath}, {filePath, "retries"}, {filePath, filePath}} {
+		for _, command := range []func(string, []string) error{inspect, get, dump, verify, diff} {
+			run(command, schemaPath, args...)
+		}
+	}
+	if current, err := ioutil.ReadFile(filePath); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(current, data) {
+		t.Fatal("file must be left intact")
+	}
+	if _, err := run(set, schemaPath, filePath, "retries", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := ioutil.ReadFile(filePath); err != nil {
+		t.Fatal(err)
+	} else if current[info.SignSize]%2 != 0 {
+		t.Fatal("sequence must be repaired")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	filePath, schemaPath := testFile(t, nil)
+	// Baseline format 1.0.0 has neither header nor entry versions.
+	legacy := []byte{'M', 'E', 'M', 1, 0, 0, byte(stateholder.KindBytes), 5, 0, byte(stateholder.KindUint32), 4, 0, byte(stateholder.KindByte), 1, 0}
+	legacy = append(legacy, 'h', 'e', 'l', 'l', 'o', 7, 0, 0, 0, 1)
+	if err := ioutil.WriteFile(filePath, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(get, schemaPath, filePath, "retries"); err == nil {
+		t.Fatal("expected ErrorBadFile, nil error found")
+	} else if _, ok := err.(*stateholder.ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+	if out, err := run(migrate, "", filePath); err != nil {
+		t.Fatal(err)
+	} else if out != "MEM 1.0.0 -> MEM 3.0.0\n" {
+		t.Fatalf("unexpected migrate %q", out)
+	}
+	if out, err := run(dump, schemaPath, filePath); err != nil {
+		t.Fatal(err)
+	} else if out != "name     68656c6c6f\nretries  7\nflag     1\n" {
+		t.Fatalf("unexpected dump %q", out)
+	}
+}
@@ -0,0 +1,149 @@
+// Command stateholder inspects and edits stateholder files.
+//
+// Entries are described by an external JSON schema given with -schema flag:
+//
+//	[
+//		{"key": "retries", "kind": "uint32"},
+//		{"key": "name", "kind": "bytes", "size": 16}
+//	]
+//
+// Without -schema the entry table embedded into the default file signature is used.
+// It has no key names, so entries are addressed by their indexes.
+// Double-buffered files are detected by their signature.
+//
+// File with custom signature given with -sign flag requires -schema,
+// it is double-buffered only if -double-buffered flag is given.
+// Commands which do not modify file attach it read-only.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+// Error occurred when command line is invalid.
+var errUsage = errors.New("stateholder: invalid usage")
+
+// Command output.
+var output io.Writer = os.Stdout
+
+// Custom file signature, default one is used if it is empty.
+var customSign string
+
+// Whether file with custom signature is double-buffered.
+var doubleBuffered bool
+
+// Available commands.
+var commands = map[string]func(schemaPath string, args []string) error{
+	"inspect": inspect,
+	"get":     get,
+	"set":     set,
+	"inc":     inc,
+	"dump":    dump,
+	"verify":  verify,
+	"diff":    diff,
+	"migrate": migrate,
+}
+
+// Print usage.
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: stateholder [-schema path [-sign signature [-double-buffered]]] command [arguments]
+
+commands:
+  inspect file            print header and entry table
+  get file key            print entry value
+  set file key value      set entry value
+  inc file key [delta]    increment integer entry
+  dump [-json] file       print all entries
+  verify file             verify file against schema
+  diff file file|snapshot print entries which differ
+  migrate file            upgrade file of earlier format
+
+Integer values are decimal numbers, byte arrays are hexadecimal strings.
+`)
+}
+
+func main() {
+	schemaPath := flag.String("schema", "", "external schema `path`")
+	flag.StringVar(&customSign, "sign", "", "custom file `signature`")
+	flag.BoolVar(&doubleBuffered, "double-buffered", false, "file with custom signature is double-buffered")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := command(*schemaPath, flag.Args()[1:]); err == errUsage {
+		usage()
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Open existing file with layout read from its signature or given with flags.
+func open(filePath, schemaPath string, readOnly bool) (*stateholder.Stateholder, schema, *stateholder.FileInfo, error) {
+	info, err := readFileInfo(filePath, schemaPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	entries := embeddedSchema(info)
+	if schemaPath != "" {
+		if entries, err = loadSchema(schemaPath); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	sh, err := entries.stateholder()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := attach(sh, filePath, info, readOnly); err != nil {
+		sh.Close()
+		return nil, nil, nil, err
+	}
+	if customSign != "" {
+		copies := 1
+		if info.DoubleBuffered {
+			copies = 2
+		}
+		info.HeaderSize = int(info.Size) - info.SignSize - copies*int(sh.Size())
+	}
+	return sh, entries, info, nil
+}
+
+// Read information of file with default signature.
+// File with custom signature is described by flags and requires external schema.
+func readFileInfo(filePath, schemaPath string) (*stateholder.FileInfo, error) {
+	if customSign == "" {
+		return stateholder.ReadFileInfo(filePath)
+	}
+	if schemaPath == "" {
+		return nil, errUsage
+	}
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &stateholder.FileInfo{DoubleBuffered: doubleBuffered, Size: stat.Size(), SignSize: len(customSign)}, nil
+}
+
+// Attach existing file described by information.
+func attach(sh *stateholder.Stateholder, filePath string, info *stateholder.FileInfo, readOnly bool) error {
+	options := &stateholder.Options{DoubleBuffered: info.DoubleBuffered, ReadOnly: readOnly}
+	if customSign != "" {
+		options.Sign = []byte(customSign)
+	}
+	_, err := sh.AttachWithOptions(filePath, options)
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+// Kind names.
+var kinds = map[string]stateholder.Kind{
+	"bytes":  stateholder.KindBytes,
+	"byte":   stateholder.KindByte,
+	"uint16": stateholder.KindUint16,
+	"uint32": stateholder.KindUint32,
+	"uint64": stateholder.KindUint64,
+}
+
+type schemaEntry struct {
+	// Schema entry.
+
+	// Key.
+	Key string
+
+	// Kind.
+	Kind stateholder.Kind
+
+	// Size of byte array.
+	Size stateholder.EntrySize
+}
+
+// Schema.
+type schema []schemaEntry
+
+// Make stateholder.
+func (s schema) stateholder() (*stateholder.Stateholder, error) {
+	sh := stateholder.NewStateholder()
+	for _, entry := range s {
+		var err error
+		switch entry.Kind {
+		case stateholder.KindBytes:
+			err = sh.Define(entry.Key, entry.Size)
+		case stateholder.KindByte:
+			err = sh.DefineByte(entry.Key)
+		case stateholder.KindUint16:
+			err = sh.DefineUint16(entry.Key)
+		case stateholder.KindUint32:
+			err = sh.DefineUint32(entry.Key)
+		case stateholder.KindUint64:
+			err = sh.DefineUint64(entry.Key)
+		}
+		if err != nil {
+			sh.Close()
+			return nil, err
+		}
+	}
+	return sh, nil
+}
+
+// Load external schema.
+func loadSchema(schemaPath string) (schema, error) {
+	data, err := ioutil.ReadFile(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Key  string                `json:"key"`
+		Kind string                `json:"kind"`
+		Size stateholder.EntrySize `json:"size"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("stateholder: bad schema %s: %v", schemaPath, err)
+	}
+	var s schema
+	for _, entry := range entries {
+		kind, ok := kinds[entry.Kind]
+		if !ok {
+			return nil, fmt.Errorf("stateholder: unknown kind %q of %q", entry.Kind, entry.Key)
+		}
+		s = append(s, schemaEntry{Key: entry.Key, Kind: kind, Size: entry.Size})
+	}
+	return s, nil
+}
+
+// Derive schema from default signature embedded into file.
+// Entries are named by their indexes.
+func embeddedSchema(info *stateholder.FileInfo) schema {
+	s := make(schema, len(info.Entries))
+	for i, entry := range info.Entries {
+		s[i] = schemaEntry{Key: strconv.Itoa(i), Kind: entry.Kind, Size: entry.Size}
+	}
+	return s
+}
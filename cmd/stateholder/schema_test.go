@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+var testSchema = []byte(`[
+	{"key": "name", "kind": "bytes", "size": 5},
+	{"key": "retries", "kind": "uint32"},
+	{"key": "flag", "kind": "byte"}
+]`)
+
+// Write test schema and create file described by it.
+func testFile(t *testing.T, options *stateholder.Options) (string, string) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "test.json")
+	if err := ioutil.WriteFile(schemaPath, testSchema, 0600); err != nil {
+		t.Fatal(err)
+	}
+	external, err := loadSchema(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := external.stateholder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+	filePath := filepath.Join(dir, "test.mem")
+	if _, err := sh.AttachWithOptions(filePath, options); err != nil {
+		t.Fatal(err)
+	}
+	return filePath, schemaPath
+}
+
+func TestSchema(t *testing.T) {
+	filePath, schemaPath := testFile(t, nil)
+	external, err := loadSchema(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := external.stateholder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+	if size := sh.Size(); size != 34 {
+		t.Fatalf("size must be a %d, %d found", 34, size)
+	}
+	info, err := stateholder.ReadFileInfo(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded := embeddedSchema(info)
+	if len(embedded) != len(external) {
+		t.Fatalf("schema must have %d entries, %d found", len(external), len(embedded))
+	}
+	for i, entry := range info.Entries {
+		expected, err := sh.Describe(external[i].Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != expected {
+			t.Fatalf("entry %d must be a %v, %v found", i, expected, entry)
+		}
+	}
+	if embedded[1].Kind != stateholder.KindUint32 {
+		t.Fatalf("entry 1 must be a %s, %s found", stateholder.KindUint32, embedded[1].Kind)
+	}
+}
@@ -0,0 +1,41 @@
+package stateholder
+
+import "context"
+
+// Sync data or fail when context is done.
+// Sync which is already started is not interrupted, its error is available via SyncError.
+func (sh *Stateholder) SyncContext(ctx context.Context) error {
+	if err := sh.rlockContext(ctx); err != nil {
+		return err
+	}
+	if sh.index == nil {
+		sh.mutex.RUnlock()
+		return &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		sh.mutex.RUnlock()
+		return &ErrorDetached{}
+	}
+	if ctx.Done() == nil {
+		defer sh.mutex.RUnlock()
+		return sh.sync()
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer sh.mutex.RUnlock()
+		done <- sh.sync()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		go func() {
+			if err := <-done; err != nil {
+				sh.syncMutex.Lock()
+				sh.syncErr = err
+				sh.syncMutex.Unlock()
+			}
+		}()
+		return ctx.Err()
+	}
+}
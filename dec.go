@@ -3,22 +3,36 @@ package stateholder
 import "encoding/binary"
 
 // Decrement byte.
-func (sh *Stateholder) DecByte(key string, delta byte) (byte, byte, error) {
-	entry, buffer, err := sh.get(key, KindByte)
+func decByte(a accessor, key string, delta byte) (byte, byte, error) {
+	entry, buffer, err := get(a, key, KindByte)
 	if err != nil {
 		return 0, 0, err
 	}
 	old := buffer[0]
 	buffer[0] -= delta
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, buffer[0], nil
 }
 
+// Decrement byte.
+func (sh *Stateholder) DecByte(key string, delta byte) (byte, byte, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return decByte(sh, key, delta)
+}
+
+// Decrement byte within transaction.
+func (tx *Tx) DecByte(key string, delta byte) (byte, byte, error) {
+	return decByte(tx, key, delta)
+}
+
 // Decrement 16-bit unsigned integer value.
-func (sh *Stateholder) DecUint16(key string, delta uint16) (uint16, uint16, error) {
-	entry, buffer, err := sh.get(key, KindUint16)
+func decUint16(a accessor, key string, delta uint16) (uint16, uint16, error) {
+	entry, buffer, err := get(a, key, KindUint16)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -26,15 +40,29 @@ func (sh *Stateholder) DecUint16(key string, delta uint16) (uint16, uint16, erro
 	old := value
 	value -= delta
 	binary.LittleEndian.PutUint16(buffer, value)
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, value, nil
 }
 
+// Decrement 16-bit unsigned integer value.
+func (sh *Stateholder) DecUint16(key string, delta uint16) (uint16, uint16, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return decUint16(sh, key, delta)
+}
+
+// Decrement 16-bit unsigned integer value within transaction.
+func (tx *Tx) DecUint16(key string, delta uint16) (uint16, uint16, error) {
+	return decUint16(tx, key, delta)
+}
+
 // Decrement to 32-bit unsigned integer value.
-func (sh *Stateholder) DecUint32(key string, delta uint32) (uint32, uint32, error) {
-	entry, buffer, err := sh.get(key, KindUint32)
+func decUint32(a accessor, key string, delta uint32) (uint32, uint32, error) {
+	entry, buffer, err := get(a, key, KindUint32)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -42,15 +70,29 @@ func (sh *Stateholder) DecUint32(key string, delta uint32) (uint32, uint32, erro
 	old := value
 	value -= delta
 	binary.LittleEndian.PutUint32(buffer, value)
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, value, nil
 }
 
+// Decrement to 32-bit unsigned integer value.
+func (sh *Stateholder) DecUint32(key string, delta uint32) (uint32, uint32, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return decUint32(sh, key, delta)
+}
+
+// Decrement to 32-bit unsigned integer value within transaction.
+func (tx *Tx) DecUint32(key string, delta uint32) (uint32, uint32, error) {
+	return decUint32(tx, key, delta)
+}
+
 // Decrement 64-bit unsigned integer value.
-func (sh *Stateholder) DecUint64(key string, delta uint64) (uint64, uint64, error) {
-	entry, buffer, err := sh.get(key, KindUint64)
+func decUint64(a accessor, key string, delta uint64) (uint64, uint64, error) {
+	entry, buffer, err := get(a, key, KindUint64)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -58,8 +100,22 @@ func (sh *Stateholder) DecUint64(key string, delta uint64) (uint64, uint64, erro
 	old := value
 	value -= delta
 	binary.LittleEndian.PutUint64(buffer, value)
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, value, nil
 }
+
+// Decrement 64-bit unsigned integer value.
+func (sh *Stateholder) DecUint64(key string, delta uint64) (uint64, uint64, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return decUint64(sh, key, delta)
+}
+
+// Decrement 64-bit unsigned integer value within transaction.
+func (tx *Tx) DecUint64(key string, delta uint64) (uint64, uint64, error) {
+	return decUint64(tx, key, delta)
+}
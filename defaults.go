@@ -0,0 +1,88 @@
+package stateholder
+
+import "fmt"
+
+// Entry definition option.
+type DefineOption func(entry *entry) error
+
+// Set default value which is written when file is created, entry is defined live or reset.
+// Integer entries accept any integer or decimal string,
+// byte arrays accept byte slice or string which is padded with zeros.
+func WithDefault(value interface{}) DefineOption {
+	return func(entry *entry) error {
+		if entry.kind == KindBytes {
+			var buffer []byte
+			switch v := value.(type) {
+			case []byte:
+				buffer = v
+			case string:
+				buffer = []byte(v)
+			default:
+				return &ErrorInvalidValue{Key: entry.key, Value: fmt.Sprint(value)}
+			}
+			if len(buffer) > int(entry.size) {
+				return &ErrorInvalidValue{Key: entry.key, Value: fmt.Sprint(value)}
+			}
+			entry.initial = make([]byte, entry.size)
+			copy(entry.initial, buffer)
+			return nil
+		}
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, string:
+		default:
+			return &ErrorInvalidValue{Key: entry.key, Value: fmt.Sprint(value)}
+		}
+		buffer, ok := entry.kind.parse(fmt.Sprint(value))
+		if !ok {
+			return &ErrorInvalidValue{Key: entry.key, Value: fmt.Sprint(value)}
+		}
+		entry.initial = buffer
+		return nil
+	}
+}
+
+// Reset entry to default value.
+func reset(a accessor, key string) error {
+	entry, err := a.lookup(key)
+	if err != nil {
+		return err
+	}
+	return a.write(entry, entry.defaultValue())
+}
+
+// Reset entry to default value.
+func (sh *Stateholder) Reset(key string) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return reset(sh, key)
+}
+
+// Reset entry to default value within transaction.
+func (tx *Tx) Reset(key string) error {
+	return reset(tx, key)
+}
+
+// Reset all entries to default values.
+func (sh *Stateholder) ResetAll() error {
+	return sh.Update(func(tx *Tx) error {
+		return tx.ResetAll()
+	})
+}
+
+// Reset all entries to default values within transaction.
+func (tx *Tx) ResetAll() error {
+	if tx.done {
+		return &ErrorTransactionFinished{}
+	}
+	if tx.readOnly {
+		return &ErrorReadOnly{}
+	}
+	for _, key := range tx.sh.Keys() {
+		if err := tx.Reset(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
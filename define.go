@@ -1,48 +1,191 @@
 package stateholder
 
-import "github.com/alexeymaximov/syspack"
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+// Make entry which follows defined ones.
+func (sh *Stateholder) newEntry(key string, kind Kind, size EntrySize, options []DefineOption) (*entry, error) {
+	if _, ok := sh.index[key]; ok {
+		return nil, &ErrorAmbiguous{Key: key}
+	}
+	if kind > KindUint64 {
+		return nil, &ErrorInvalidKind{Key: key, Kind: kind}
+	}
+	if size <= 0 || kind.size() != 0 && kind.size() != size {
+		return nil, &ErrorInvalidSize{Key: key, Size: size}
+	}
+	entry := &entry{key: key, kind: kind, offset: syspack.Offset(sh.size + versionSize), size: size}
+	for _, option := range options {
+		if err := option(entry); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// Append entry to layout.
+func (sh *Stateholder) appendEntry(entry *entry) {
+	sh.index[entry.key] = len(sh.entries)
+	sh.entries = append(sh.entries, entry)
+	sh.size += versionSize + syspack.Size(entry.size)
+}
 
 // Define entry.
-func (sh *Stateholder) define(key string, kind Kind, size EntrySize) error {
+func (sh *Stateholder) define(key string, kind Kind, size EntrySize, options []DefineOption) error {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
 	if sh.index == nil {
 		return &ErrorClosed{}
 	}
-	if sh.mapping != nil {
+	if sh.backend != nil {
 		return &ErrorAttached{}
 	}
-	if _, ok := sh.index[key]; ok {
-		return &ErrorAmbiguous{Key: key}
+	entry, err := sh.newEntry(key, kind, size, options)
+	if err != nil {
+		return err
+	}
+	sh.appendEntry(entry)
+	return nil
+}
+
+// Define entry while file is attached.
+// Entry is appended to the end of layout initialized with its default value
+// and file is grown in place under file lock, change sequence number is advanced.
+// Other processes sharing the file must attach it again to see the entry,
+// their reads and writes fail with ErrorBadFile until then.
+// Growth is not atomic, so file may be left bad in case of crash.
+// File attached with MemoryBackend is left intact and memory is grown only.
+// It fails with ErrorAttached if file was attached with custom signature.
+func (sh *Stateholder) DefineLive(key string, kind Kind, size EntrySize, options ...DefineOption) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	if sh.index == nil {
+		return &ErrorClosed{}
+	}
+	entry, err := sh.newEntry(key, kind, size, options)
+	if err != nil {
+		return err
+	}
+	if sh.backend == nil {
+		sh.appendEntry(entry)
+		return nil
+	}
+	if sh.readOnly {
+		return &ErrorReadOnly{}
+	}
+	if bytes.Compare(sh.sign, sh.defaultSign(sh.buffered)) != 0 {
+		return &ErrorAttached{}
+	}
+	if err := sh.checkFile(); err != nil {
+		return err
+	}
+	region := make([]byte, regionSize(sh.buffered, sh.size))
+	if n, err := sh.backend.ReadAt(region, 0); err != nil {
+		return err
+	} else if n != len(region) {
+		return &ErrorCorruptedRead{Real: n, Expected: len(region)}
+	}
+	oldSize := sh.size
+	sh.appendEntry(entry)
+	grown := make([]byte, regionSize(sh.buffered, sh.size))
+	copy(grown, region[:dataOffset(sh.buffered, 0, oldSize)])
+	seq := binary.LittleEndian.Uint64(grown)
+	binary.LittleEndian.PutUint64(grown, seq+2-seq%2)
+	copies := 1
+	if sh.buffered {
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		old := dataOffset(sh.buffered, uint64(i), oldSize)
+		base := dataOffset(sh.buffered, uint64(i), sh.size)
+		copy(grown[base:], region[old:old+syspack.Offset(oldSize)])
+		copy(grown[base+entry.offset:], entry.initial)
+	}
+	if err := sh.grow(region, grown); err != nil {
+		sh.undefine(oldSize)
+		return err
+	}
+	return nil
+}
+
+// Remove last entry from layout.
+func (sh *Stateholder) undefine(size syspack.Size) {
+	last := sh.entries[len(sh.entries)-1]
+	delete(sh.index, last.key)
+	sh.entries = sh.entries[:len(sh.entries)-1]
+	sh.size = size
+}
+
+// Grow attached file and backend in place from old region to given one.
+// Memory backend is never written back to file, so it is grown in memory only.
+// Caller must hold file lock.
+func (sh *Stateholder) grow(old, region []byte) error {
+	sign := sh.defaultSign(sh.buffered)
+	if _, ok := sh.backend.(memoryBackend); ok || sh.file == nil {
+		backend := NewMemoryBackend(syspack.Size(len(region)))
+		copy(backend.Bytes(), region)
+		sh.backend.Close()
+		sh.sign = sign
+		sh.backend = backend
+		return nil
+	}
+	if err := sh.sync(); err != nil {
+		return err
+	}
+	file := sh.file
+	oldLen := int64(len(sh.sign) + len(old))
+	// Restore old layout, so file remains usable if it is possible.
+	restore := func() {
+		sh.prepareFile(file, sh.sign, old)
+		file.Truncate(oldLen)
+	}
+	if err := file.Truncate(int64(len(sign) + len(region))); err != nil {
+		return err
+	}
+	if err := sh.prepareFile(file, sign, region); err != nil {
+		restore()
+		return err
 	}
-	if size <= 0 {
-		return &ErrorInvalidSize{Key: key, Size: size}
+	// Backend is opened after file is rewritten, so backends which read file on open see grown region.
+	backend, err := sh.factory(file, syspack.Offset(len(sign)), syspack.Size(len(region)))
+	if err != nil {
+		restore()
+		return err
 	}
-	sh.index[key] = len(sh.entries)
-	sh.entries = append(sh.entries, &entry{kind: kind, offset: syspack.Offset(sh.size), size: size})
-	sh.size += syspack.Size(size)
+	sh.backend.Close()
+	sh.sign = sign
+	sh.backend = backend
+	sh.dirty.take()
 	return nil
 }
 
 // Define byte array.
-func (sh *Stateholder) Define(key string, size EntrySize) error {
-	return sh.define(key, KindBytes, size)
+func (sh *Stateholder) Define(key string, size EntrySize, options ...DefineOption) error {
+	return sh.define(key, KindBytes, size, options)
 }
 
 // Define byte.
-func (sh *Stateholder) DefineByte(key string) error {
-	return sh.define(key, KindByte, 1)
+func (sh *Stateholder) DefineByte(key string, options ...DefineOption) error {
+	return sh.define(key, KindByte, 1, options)
 }
 
 // Define 16-bit unsigned integer value.
-func (sh *Stateholder) DefineUint16(key string) error {
-	return sh.define(key, KindUint16, 2)
+func (sh *Stateholder) DefineUint16(key string, options ...DefineOption) error {
+	return sh.define(key, KindUint16, 2, options)
 }
 
 // Define 32-bit unsigned integer value.
-func (sh *Stateholder) DefineUint32(key string) error {
-	return sh.define(key, KindUint32, 4)
+func (sh *Stateholder) DefineUint32(key string, options ...DefineOption) error {
+	return sh.define(key, KindUint32, 4, options)
 }
 
 // Define 64-bit unsigned integer value.
-func (sh *Stateholder) DefineUint64(key string) error {
-	return sh.define(key, KindUint64, 8)
+func (sh *Stateholder) DefineUint64(key string, options ...DefineOption) error {
+	return sh.define(key, KindUint64, 8, options)
 }
@@ -0,0 +1,72 @@
+package stateholder
+
+import "github.com/alexeymaximov/syspack"
+
+// Get keys in definition order.
+func (sh *Stateholder) Keys() []string {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	keys := make([]string, len(sh.entries))
+	for i, entry := range sh.entries {
+		keys[i] = entry.key
+	}
+	return keys
+}
+
+// Get entry information.
+func (sh *Stateholder) Describe(key string) (EntryInfo, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return EntryInfo{}, &ErrorClosed{}
+	}
+	index, ok := sh.index[key]
+	if !ok {
+		return EntryInfo{}, &ErrorUndefined{Key: key}
+	}
+	return sh.entries[index].info(), nil
+}
+
+// Get data size.
+func (sh *Stateholder) Size() syspack.Size {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return sh.size
+}
+
+// Call function for each entry in definition order until it returns false.
+// Values are read before the first call, so function may use stateholder.
+func (sh *Stateholder) Range(fn func(key string, info EntryInfo, value []byte) bool) error {
+	entries, values, err := sh.readAll()
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		if !fn(entry.key, entry.info(), values[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+// Read entries and their values.
+func (sh *Stateholder) readAll() ([]*entry, [][]byte, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return nil, nil, &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		return nil, nil, &ErrorDetached{}
+	}
+	entries := sh.entries
+	values := make([][]byte, len(entries))
+	for i, entry := range entries {
+		value, err := sh.read(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = value
+	}
+	return entries, values, nil
+}
@@ -0,0 +1,77 @@
+package stateholder
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Entry change.
+// Value is nil when entry is undefined.
+type Change struct {
+	Key  string
+	Kind Kind
+	Old  []byte
+	New  []byte
+}
+
+// Stringify change.
+func (change Change) String() string {
+	old, value := "undefined", "undefined"
+	if change.Old != nil {
+		old = change.Kind.format(change.Old)
+	}
+	if change.New != nil {
+		value = change.Kind.format(change.New)
+	}
+	return fmt.Sprintf("%s: %s -> %s", change.Key, old, value)
+}
+
+// Get index of entry which is defined with the same key, kind and size or -1.
+func compatible(entries []*entry, index map[string]int, other *entry) int {
+	i, ok := index[other.key]
+	if !ok || entries[i].kind != other.kind || entries[i].size != other.size {
+		return -1
+	}
+	return i
+}
+
+// Index entries by keys.
+func indexEntries(entries []*entry) map[string]int {
+	index := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		index[entry.key] = i
+	}
+	return index
+}
+
+// Get changes between two stateholders.
+// Entries which are undefined in one of stateholders or defined with different kind or size
+// have nil value on that side.
+// Each stateholder is read under its own lock, so they are not locked at the same time.
+func Diff(a, b *Stateholder) ([]Change, error) {
+	entriesA, valuesA, err := a.readAll()
+	if err != nil {
+		return nil, err
+	}
+	entriesB, valuesB, err := b.readAll()
+	if err != nil {
+		return nil, err
+	}
+	indexA, indexB := indexEntries(entriesA), indexEntries(entriesB)
+	var changes []Change
+	for i, entry := range entriesA {
+		var value []byte
+		if j := compatible(entriesB, indexB, entry); j >= 0 {
+			value = valuesB[j]
+		}
+		if bytes.Compare(valuesA[i], value) != 0 {
+			changes = append(changes, Change{Key: entry.key, Kind: entry.kind, Old: valuesA[i], New: value})
+		}
+	}
+	for j, entry := range entriesB {
+		if compatible(entriesA, indexA, entry) < 0 {
+			changes = append(changes, Change{Key: entry.key, Kind: entry.kind, New: valuesB[j]})
+		}
+	}
+	return changes, nil
+}
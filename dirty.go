@@ -0,0 +1,102 @@
+package stateholder
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+// Dirty page size.
+var pageSize = syspack.Offset(os.Getpagesize())
+
+type dirtyRange struct {
+	// Dirty range.
+
+	// Offset.
+	offset syspack.Offset
+
+	// Size.
+	size syspack.Size
+}
+
+type dirtyPages struct {
+	// Pages written since last sync.
+
+	// Mutex.
+	mutex sync.Mutex
+
+	// Page indexes.
+	pages map[syspack.Offset]struct{}
+}
+
+// Mark region as dirty.
+func (d *dirtyPages) mark(offset syspack.Offset, size int) {
+	if size <= 0 {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.pages == nil {
+		d.pages = make(map[syspack.Offset]struct{})
+	}
+	for page := offset / pageSize; page <= (offset+syspack.Offset(size)-1)/pageSize; page++ {
+		d.pages[page] = struct{}{}
+	}
+}
+
+// Take coalesced dirty ranges and clear them.
+func (d *dirtyPages) take() []dirtyRange {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	pages := make([]syspack.Offset, 0, len(d.pages))
+	for page := range d.pages {
+		pages = append(pages, page)
+	}
+	d.pages = nil
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i] < pages[j]
+	})
+	var ranges []dirtyRange
+	for _, page := range pages {
+		if n := len(ranges); n > 0 && ranges[n-1].offset+syspack.Offset(ranges[n-1].size) == page*pageSize {
+			ranges[n-1].size += syspack.Size(pageSize)
+			continue
+		}
+		ranges = append(ranges, dirtyRange{offset: page * pageSize, size: syspack.Size(pageSize)})
+	}
+	return ranges
+}
+
+// Mark ranges as dirty again.
+func (d *dirtyPages) restore(ranges []dirtyRange) {
+	for _, r := range ranges {
+		d.mark(r.offset, int(r.size))
+	}
+}
+
+// Write data to backend and mark it dirty.
+func (sh *Stateholder) writeAt(buffer []byte, offset syspack.Offset) (int, error) {
+	n, err := sh.backend.WriteAt(buffer, offset)
+	sh.dirty.mark(offset, n)
+	return n, err
+}
+
+// Sync data.
+// Only dirty ranges are synced if backend supports it.
+func (sh *Stateholder) sync() error {
+	syncer, ok := sh.backend.(RangeSyncer)
+	if !ok {
+		sh.dirty.take()
+		return sh.backend.Sync()
+	}
+	ranges := sh.dirty.take()
+	for i, r := range ranges {
+		if err := syncer.SyncRange(r.offset, r.size); err != nil {
+			sh.dirty.restore(ranges[i:])
+			return err
+		}
+	}
+	return nil
+}
@@ -5,9 +5,23 @@ import "github.com/alexeymaximov/syspack"
 // Entry size.
 type EntrySize = uint16
 
+// Entry version size.
+// Version precedes entry value and is incremented on every write.
+const versionSize = 8
+
+// Entry information.
+type EntryInfo struct {
+	Kind   Kind
+	Size   EntrySize
+	Offset syspack.Offset
+}
+
 type entry struct {
 	// Entry.
 
+	// Key.
+	key string
+
 	// Kind.
 	kind Kind
 
@@ -17,6 +31,19 @@ type entry struct {
 	// Size.
 	size EntrySize
 
-	// Buffer.
-	buffer []byte
+	// Default value, it is zeroed if nil.
+	initial []byte
+}
+
+// Get default value.
+func (entry *entry) defaultValue() []byte {
+	if entry.initial == nil {
+		return make([]byte, entry.size)
+	}
+	return append([]byte(nil), entry.initial...)
+}
+
+// Get entry information.
+func (entry *entry) info() EntryInfo {
+	return EntryInfo{Kind: entry.kind, Size: entry.size, Offset: entry.offset}
 }
@@ -26,6 +26,14 @@ func (err *ErrorBadFile) Error() string {
 	return fmt.Sprintf("stateholder: bad file %s", err.Path)
 }
 
+// Error occurred when snapshot is bad.
+type ErrorBadSnapshot struct{}
+
+// Get error message.
+func (err *ErrorBadSnapshot) Error() string {
+	return "stateholder: bad snapshot"
+}
+
 // Error occurred when stateholder closed.
 type ErrorClosed struct{}
 
@@ -34,6 +42,14 @@ func (err *ErrorClosed) Error() string {
 	return "stateholder: closed"
 }
 
+// Error occurred when entry was modified concurrently.
+type ErrorConflict struct{ Key string }
+
+// Get error message.
+func (err *ErrorConflict) Error() string {
+	return fmt.Sprintf("stateholder: conflict on %q", err.Key)
+}
+
 // Error occurred on read corruption.
 type ErrorCorruptedRead struct{ Real, Expected int }
 
@@ -82,6 +98,17 @@ func (err *ErrorIncompatibleSize) Error() string {
 	return fmt.Sprintf("stateholder: %q size is %d bytes, not %d", err.Key, err.Size, err.GivenSize)
 }
 
+// Error occurred when entry kind is invalid.
+type ErrorInvalidKind struct {
+	Key  string
+	Kind Kind
+}
+
+// Get error message.
+func (err *ErrorInvalidKind) Error() string {
+	return fmt.Sprintf("stateholder: kind %d of %q is invalid", err.Kind, err.Key)
+}
+
 // Error occurred when entry size is invalid.
 type ErrorInvalidSize struct {
 	Key  string
@@ -93,20 +120,39 @@ func (err *ErrorInvalidSize) Error() string {
 	return fmt.Sprintf("stateholder: size %d of %q is invalid", err.Size, err.Key)
 }
 
-// Error occurred when transaction not started.
-type ErrorTransactionNotStarted struct{}
+// Error occurred when entry value is invalid.
+type ErrorInvalidValue struct {
+	Key   string
+	Value string
+}
 
 // Get error message.
-func (err *ErrorTransactionNotStarted) Error() string {
-	return "stateholder: transaction not started"
+func (err *ErrorInvalidValue) Error() string {
+	return fmt.Sprintf("stateholder: value %q of %q is invalid", err.Value, err.Key)
 }
 
-// Error occurred when transaction already started.
-type ErrorTransactionAlreadyStarted struct{}
+// Error occurred on write within read-only transaction or to file attached read-only.
+type ErrorReadOnly struct{}
 
 // Get error message.
-func (err *ErrorTransactionAlreadyStarted) Error() string {
-	return "stateholder: transaction already started"
+func (err *ErrorReadOnly) Error() string {
+	return "stateholder: read-only"
+}
+
+// Error occurred when transaction already finished.
+type ErrorTransactionFinished struct{}
+
+// Get error message.
+func (err *ErrorTransactionFinished) Error() string {
+	return "stateholder: transaction finished"
+}
+
+// Error occurred when detaching while transaction is open.
+type ErrorTransactionOpen struct{}
+
+// Get error message.
+func (err *ErrorTransactionOpen) Error() string {
+	return "stateholder: transaction is open"
 }
 
 // Error occurred when key is undefined.
@@ -116,3 +162,11 @@ type ErrorUndefined struct{ Key string }
 func (err *ErrorUndefined) Error() string {
 	return fmt.Sprintf("stateholder: undefined %q", err.Key)
 }
+
+// Error occurred when savepoint is undefined.
+type ErrorUndefinedSavepoint struct{ Name string }
+
+// Get error message.
+func (err *ErrorUndefinedSavepoint) Error() string {
+	return fmt.Sprintf("stateholder: undefined savepoint %q", err.Name)
+}
@@ -0,0 +1,24 @@
+package stateholder
+
+import (
+	"encoding/json"
+	"expvar"
+)
+
+// Expvar variable.
+type expvarVar struct{ sh *Stateholder }
+
+// Get entries as JSON object or error message as JSON string.
+func (v expvarVar) String() string {
+	data, err := v.sh.MarshalJSON()
+	if err != nil {
+		data, _ = json.Marshal(err.Error())
+	}
+	return string(data)
+}
+
+// Publish entries as expvar variable.
+// It panics if variable with the same name is already published.
+func PublishExpvar(name string, sh *Stateholder) {
+	expvar.Publish(name, expvarVar{sh: sh})
+}
@@ -0,0 +1,88 @@
+package stateholder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+// Default signature header size.
+// Header contains format magic and version.
+const signHeaderSize = 6
+
+// Default signature entry size.
+// Entry contains kind and little-endian 16-bit size.
+const signEntrySize = 3
+
+type FileInfo struct {
+	// Information of file with default signature.
+
+	// Format magic, MEM or MED for double-buffered file.
+	Magic string
+
+	// Format version.
+	Version string
+
+	// Data is double-buffered.
+	DoubleBuffered bool
+
+	// File size.
+	Size int64
+
+	// Signature size.
+	SignSize int
+
+	// Size of header and active copy pointer preceding data.
+	HeaderSize int
+
+	// Entries described by signature in definition order.
+	Entries []EntryInfo
+}
+
+// Read information of file with default signature, including files of legacy formats.
+// It fails with ErrorBadFile if file has custom signature or its size does not match signature.
+func ReadFileInfo(filePath string) (*FileInfo, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < signHeaderSize || data[0] != 'M' || data[1] != 'E' || data[2] != 'M' && data[2] != 'D' {
+		return nil, &ErrorBadFile{Path: filePath}
+	}
+	info := &FileInfo{
+		Magic:          string(data[:3]),
+		Version:        fmt.Sprintf("%d.%d.%d", data[3], data[4], data[5]),
+		DoubleBuffered: data[2] == 'D',
+		Size:           int64(len(data)),
+		SignSize:       signHeaderSize,
+		HeaderSize:     int(regionSize(data[2] == 'D', 0)),
+	}
+	region := func(size syspack.Size) syspack.Size {
+		return regionSize(info.DoubleBuffered, size)
+	}
+	version := syspack.Size(versionSize)
+	if format, ok := legacy(data); ok {
+		info.HeaderSize = int(format.header)
+		region = func(size syspack.Size) syspack.Size {
+			return format.header + size
+		}
+		version = format.version
+	}
+	var size syspack.Size
+	for int64(info.SignSize)+int64(region(size)) != info.Size {
+		if info.SignSize+signEntrySize > len(data) {
+			return nil, &ErrorBadFile{Path: filePath}
+		}
+		kind := Kind(data[info.SignSize])
+		entrySize := EntrySize(binary.LittleEndian.Uint16(data[info.SignSize+1:]))
+		if kind > KindUint64 || entrySize == 0 || kind.size() != 0 && kind.size() != entrySize {
+			return nil, &ErrorBadFile{Path: filePath}
+		}
+		info.Entries = append(info.Entries, EntryInfo{Kind: kind, Size: entrySize, Offset: syspack.Offset(size + version)})
+		size += version + syspack.Size(entrySize)
+		info.SignSize += signEntrySize
+	}
+	return info, nil
+}
@@ -2,23 +2,31 @@ package stateholder
 
 import "encoding/binary"
 
-// Get entry.
-func (sh *Stateholder) get(key string, kind Kind) (*entry, []byte, error) {
+// Lookup entry.
+func (sh *Stateholder) lookup(key string) (*entry, error) {
 	if sh.index == nil {
-		return nil, nil, &ErrorClosed{}
+		return nil, &ErrorClosed{}
 	}
-	if sh.mapping == nil {
-		return nil, nil, &ErrorDetached{}
+	if sh.backend == nil {
+		return nil, &ErrorDetached{}
 	}
 	index, ok := sh.index[key]
 	if !ok {
-		return nil, nil, &ErrorUndefined{Key: key}
+		return nil, &ErrorUndefined{Key: key}
+	}
+	return sh.entries[index], nil
+}
+
+// Get entry.
+func get(a accessor, key string, kind Kind) (*entry, []byte, error) {
+	entry, err := a.lookup(key)
+	if err != nil {
+		return nil, nil, err
 	}
-	entry := sh.entries[index]
 	if kind != entry.kind {
 		return nil, nil, &ErrorIncompatibleKind{Key: key, Kind: entry.kind, GivenKind: kind}
 	}
-	value, err := sh.read(entry)
+	value, err := a.read(entry)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -26,46 +34,132 @@ func (sh *Stateholder) get(key string, kind Kind) (*entry, []byte, error) {
 }
 
 // Get byte array.
-func (sh *Stateholder) Get(key string) ([]byte, error) {
-	_, value, err := sh.get(key, KindBytes)
+func getBytes(a accessor, key string) ([]byte, error) {
+	_, value, err := get(a, key, KindBytes)
 	if err != nil {
 		return nil, err
 	}
 	return value, nil
 }
 
+// Get byte array.
+func (sh *Stateholder) Get(key string) ([]byte, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return getBytes(sh, key)
+}
+
+// Get byte array within transaction.
+func (tx *Tx) Get(key string) ([]byte, error) {
+	return getBytes(tx, key)
+}
+
 // Get byte.
-func (sh *Stateholder) GetByte(key string) (byte, error) {
-	_, value, err := sh.get(key, KindByte)
+func getByte(a accessor, key string) (byte, error) {
+	_, value, err := get(a, key, KindByte)
 	if err != nil {
 		return 0, err
 	}
 	return value[0], nil
 }
 
+// Get byte.
+func (sh *Stateholder) GetByte(key string) (byte, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return getByte(sh, key)
+}
+
+// Get byte within transaction.
+func (tx *Tx) GetByte(key string) (byte, error) {
+	return getByte(tx, key)
+}
+
 // Get 16-bit unsigned integer value.
-func (sh *Stateholder) GetUint16(key string) (uint16, error) {
-	_, value, err := sh.get(key, KindUint16)
+func getUint16(a accessor, key string) (uint16, error) {
+	_, value, err := get(a, key, KindUint16)
 	if err != nil {
 		return 0, err
 	}
 	return binary.LittleEndian.Uint16(value), nil
 }
 
+// Get 16-bit unsigned integer value.
+func (sh *Stateholder) GetUint16(key string) (uint16, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return getUint16(sh, key)
+}
+
+// Get 16-bit unsigned integer value within transaction.
+func (tx *Tx) GetUint16(key string) (uint16, error) {
+	return getUint16(tx, key)
+}
+
 // Get 32-bit unsigned integer value.
-func (sh *Stateholder) GetUint32(key string) (uint32, error) {
-	_, value, err := sh.get(key, KindUint32)
+func getUint32(a accessor, key string) (uint32, error) {
+	_, value, err := get(a, key, KindUint32)
 	if err != nil {
 		return 0, err
 	}
 	return binary.LittleEndian.Uint32(value), nil
 }
 
+// Get 32-bit unsigned integer value.
+func (sh *Stateholder) GetUint32(key string) (uint32, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return getUint32(sh, key)
+}
+
+// Get 32-bit unsigned integer value within transaction.
+func (tx *Tx) GetUint32(key string) (uint32, error) {
+	return getUint32(tx, key)
+}
+
 // Get 64-bit unsigned integer value.
-func (sh *Stateholder) GetUint64(key string) (uint64, error) {
-	_, value, err := sh.get(key, KindUint64)
+func getUint64(a accessor, key string) (uint64, error) {
+	_, value, err := get(a, key, KindUint64)
 	if err != nil {
 		return 0, err
 	}
 	return binary.LittleEndian.Uint64(value), nil
 }
+
+// Get 64-bit unsigned integer value.
+func (sh *Stateholder) GetUint64(key string) (uint64, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return getUint64(sh, key)
+}
+
+// Get 64-bit unsigned integer value within transaction.
+func (tx *Tx) GetUint64(key string) (uint64, error) {
+	return getUint64(tx, key)
+}
+
+// Get value formatted according to its kind.
+func getString(a accessor, key string) (string, error) {
+	entry, err := a.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	value, err := a.read(entry)
+	if err != nil {
+		return "", err
+	}
+	return entry.kind.format(value), nil
+}
+
+// Get value formatted according to its kind.
+// Integer values are formatted as decimal numbers and byte arrays as hexadecimal strings.
+func (sh *Stateholder) GetString(key string) (string, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return getString(sh, key)
+}
+
+// Get value formatted according to its kind within transaction.
+func (tx *Tx) GetString(key string) (string, error) {
+	return getString(tx, key)
+}
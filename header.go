@@ -0,0 +1,198 @@
+package stateholder
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// Header size.
+// Header precedes data and contains change sequence number
+// which is incremented before and after every write and commit under file lock,
+// so it is odd while they are in progress.
+const headerSize = 8
+
+// Number of optimistic consistent read attempts.
+const maxReadAttempts = 8
+
+// Change polling interval bounds.
+const (
+	minPollInterval = time.Millisecond
+	maxPollInterval = 100 * time.Millisecond
+)
+
+// Read change sequence number.
+func (sh *Stateholder) sequence() (uint64, error) {
+	buffer := make([]byte, 8)
+	if n, err := sh.readRegion(buffer, 0); err != nil {
+		return 0, err
+	} else if n != len(buffer) {
+		return 0, &ErrorCorruptedRead{Real: n, Expected: len(buffer)}
+	}
+	return binary.LittleEndian.Uint64(buffer), nil
+}
+
+// Write change sequence number.
+func (sh *Stateholder) setSequence(seq uint64) error {
+	buffer := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buffer, seq)
+	if n, err := sh.writeAt(buffer, 0); err != nil {
+		return err
+	} else if n != len(buffer) {
+		return &ErrorCorruptedWrite{Real: n, Expected: len(buffer)}
+	}
+	return nil
+}
+
+// Make change sequence number odd before write.
+// Number left odd by interrupted write is advanced by two, so readers notice the change anyway.
+// Caller must hold file lock.
+func (sh *Stateholder) beginWrite() error {
+	seq, err := sh.sequence()
+	if err != nil {
+		return err
+	}
+	if seq%2 == 0 {
+		seq++
+	} else {
+		seq += 2
+	}
+	return sh.setSequence(seq)
+}
+
+// Make change sequence number even after write or repair it after interrupted one.
+// Caller must hold file lock.
+func (sh *Stateholder) endWrite() error {
+	seq, err := sh.sequence()
+	if err != nil {
+		return err
+	}
+	if seq%2 == 0 {
+		return nil
+	}
+	return sh.setSequence(seq + 1)
+}
+
+// Get change sequence number.
+// It is changed on every write and commit made by any process sharing the file.
+func (sh *Stateholder) Sequence() (uint64, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return 0, &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		return 0, &ErrorDetached{}
+	}
+	return sh.sequence()
+}
+
+// Wait until change sequence number is even and differs from given one and return it.
+// File is polled with exponential backoff.
+func (sh *Stateholder) WaitForChange(ctx context.Context, sinceSeq uint64) (uint64, error) {
+	interval := minPollInterval
+	for {
+		seq, err := sh.Sequence()
+		if err != nil {
+			return 0, err
+		}
+		if seq%2 == 0 && seq != sinceSeq {
+			return seq, nil
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+		if interval *= 2; interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+	}
+}
+
+// Call function until it observes data not modified by other processes.
+// Stateholder read lock is held during each attempt only. When attempts are exhausted,
+// for example because sequence number was left odd by crashed writer,
+// function is called under exclusive lock and sequence number is repaired
+// unless file is attached read-only.
+func (sh *Stateholder) consistently(fn func() error) error {
+	interval := minPollInterval
+	for attempt := 0; attempt < maxReadAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(interval)
+			interval *= 2
+		}
+		sh.mutex.RLock()
+		ok, err := sh.tryConsistently(fn)
+		sh.mutex.RUnlock()
+		if ok {
+			return err
+		}
+	}
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	if sh.index == nil {
+		return &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		return &ErrorDetached{}
+	}
+	if err := sh.checkFile(); err != nil {
+		return err
+	}
+	if !sh.readOnly {
+		if err := sh.endWrite(); err != nil {
+			return err
+		}
+	}
+	return fn()
+}
+
+// Call function once and return true if data was not modified meanwhile.
+// Caller must hold stateholder read lock.
+func (sh *Stateholder) tryConsistently(fn func() error) (bool, error) {
+	if sh.index == nil {
+		return true, &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		return true, &ErrorDetached{}
+	}
+	seq, err := sh.sequence()
+	if err != nil {
+		return true, err
+	}
+	if seq%2 != 0 {
+		return false, nil
+	}
+	fnErr := fn()
+	next, err := sh.sequence()
+	if err != nil {
+		return true, err
+	}
+	return next == seq, fnErr
+}
+
+// Read values of given keys consistently with writes made by other processes.
+func (sh *Stateholder) ReadConsistent(keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	err := sh.consistently(func() error {
+		for i, key := range keys {
+			entry, err := sh.lookup(key)
+			if err != nil {
+				return err
+			}
+			if values[i], err = sh.read(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -3,22 +3,36 @@ package stateholder
 import "encoding/binary"
 
 // Increment byte.
-func (sh *Stateholder) IncByte(key string, delta byte) (byte, byte, error) {
-	entry, buffer, err := sh.get(key, KindByte)
+func incByte(a accessor, key string, delta byte) (byte, byte, error) {
+	entry, buffer, err := get(a, key, KindByte)
 	if err != nil {
 		return 0, 0, err
 	}
 	old := buffer[0]
 	buffer[0] += delta
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, buffer[0], nil
 }
 
+// Increment byte.
+func (sh *Stateholder) IncByte(key string, delta byte) (byte, byte, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return incByte(sh, key, delta)
+}
+
+// Increment byte within transaction.
+func (tx *Tx) IncByte(key string, delta byte) (byte, byte, error) {
+	return incByte(tx, key, delta)
+}
+
 // Increment 16-bit unsigned integer value.
-func (sh *Stateholder) IncUint16(key string, delta uint16) (uint16, uint16, error) {
-	entry, buffer, err := sh.get(key, KindUint16)
+func incUint16(a accessor, key string, delta uint16) (uint16, uint16, error) {
+	entry, buffer, err := get(a, key, KindUint16)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -26,15 +40,29 @@ func (sh *Stateholder) IncUint16(key string, delta uint16) (uint16, uint16, erro
 	old := value
 	value += delta
 	binary.LittleEndian.PutUint16(buffer, value)
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, value, nil
 }
 
+// Increment 16-bit unsigned integer value.
+func (sh *Stateholder) IncUint16(key string, delta uint16) (uint16, uint16, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return incUint16(sh, key, delta)
+}
+
+// Increment 16-bit unsigned integer value within transaction.
+func (tx *Tx) IncUint16(key string, delta uint16) (uint16, uint16, error) {
+	return incUint16(tx, key, delta)
+}
+
 // Increment to 32-bit unsigned integer value.
-func (sh *Stateholder) IncUint32(key string, delta uint32) (uint32, uint32, error) {
-	entry, buffer, err := sh.get(key, KindUint32)
+func incUint32(a accessor, key string, delta uint32) (uint32, uint32, error) {
+	entry, buffer, err := get(a, key, KindUint32)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -42,15 +70,29 @@ func (sh *Stateholder) IncUint32(key string, delta uint32) (uint32, uint32, erro
 	old := value
 	value += delta
 	binary.LittleEndian.PutUint32(buffer, value)
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, value, nil
 }
 
+// Increment to 32-bit unsigned integer value.
+func (sh *Stateholder) IncUint32(key string, delta uint32) (uint32, uint32, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return incUint32(sh, key, delta)
+}
+
+// Increment to 32-bit unsigned integer value within transaction.
+func (tx *Tx) IncUint32(key string, delta uint32) (uint32, uint32, error) {
+	return incUint32(tx, key, delta)
+}
+
 // Increment 64-bit unsigned integer value.
-func (sh *Stateholder) IncUint64(key string, delta uint64) (uint64, uint64, error) {
-	entry, buffer, err := sh.get(key, KindUint64)
+func incUint64(a accessor, key string, delta uint64) (uint64, uint64, error) {
+	entry, buffer, err := get(a, key, KindUint64)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -58,8 +100,22 @@ func (sh *Stateholder) IncUint64(key string, delta uint64) (uint64, uint64, erro
 	old := value
 	value += delta
 	binary.LittleEndian.PutUint64(buffer, value)
-	if err := sh.write(entry, buffer); err != nil {
+	if err := a.write(entry, buffer); err != nil {
 		return 0, 0, err
 	}
 	return old, value, nil
 }
+
+// Increment 64-bit unsigned integer value.
+func (sh *Stateholder) IncUint64(key string, delta uint64) (uint64, uint64, error) {
+	if err := sh.lock(); err != nil {
+		return 0, 0, err
+	}
+	defer sh.unlock()
+	return incUint64(sh, key, delta)
+}
+
+// Increment 64-bit unsigned integer value within transaction.
+func (tx *Tx) IncUint64(key string, delta uint64) (uint64, uint64, error) {
+	return incUint64(tx, key, delta)
+}
@@ -0,0 +1,102 @@
+package stateholder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Marshal entries to JSON object.
+// Integer values are encoded as numbers and byte arrays as hexadecimal strings.
+func (sh *Stateholder) MarshalJSON() ([]byte, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return nil, &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		return nil, &ErrorDetached{}
+	}
+	buffer := new(bytes.Buffer)
+	buffer.WriteByte('{')
+	for i, entry := range sh.entries {
+		value, err := sh.read(entry)
+		if err != nil {
+			return nil, err
+		}
+		key, err := json.Marshal(entry.key)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		if entry.kind == KindBytes {
+			buffer.WriteByte('"')
+			buffer.WriteString(entry.kind.format(value))
+			buffer.WriteByte('"')
+		} else {
+			buffer.WriteString(entry.kind.format(value))
+		}
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// Export entries to JSON.
+func (sh *Stateholder) ExportJSON(w io.Writer) error {
+	data, err := sh.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Import entries from JSON.
+// All present keys are validated before any of them is written.
+func (sh *Stateholder) ImportJSON(r io.Reader) error {
+	return sh.Update(func(tx *Tx) error {
+		return tx.ImportJSON(r)
+	})
+}
+
+// Import entries from JSON within transaction.
+func (tx *Tx) ImportJSON(r io.Reader) error {
+	var object map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&object); err != nil {
+		return err
+	}
+	values := make(map[*entry][]byte, len(object))
+	for key, raw := range object {
+		entry, err := tx.lookup(key)
+		if err != nil {
+			return err
+		}
+		s := string(raw)
+		if entry.kind == KindBytes {
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return &ErrorInvalidValue{Key: key, Value: string(raw)}
+			}
+		}
+		value, ok := entry.kind.parse(s)
+		if !ok {
+			return &ErrorInvalidValue{Key: key, Value: string(raw)}
+		}
+		valueSize := EntrySize(len(value))
+		if valueSize != entry.size {
+			return &ErrorIncompatibleSize{Key: key, Size: entry.size, GivenSize: valueSize}
+		}
+		values[entry] = value
+	}
+	for entry, value := range values {
+		if err := tx.write(entry, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
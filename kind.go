@@ -1,5 +1,11 @@
 package stateholder
 
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+)
+
 // Kind.
 type Kind byte
 
@@ -29,3 +35,78 @@ func (kind Kind) String() string {
 		return "invalid kind"
 	}
 }
+
+// Get value size of integer kind or zero for byte array.
+func (kind Kind) size() EntrySize {
+	switch kind {
+	case KindByte:
+		return 1
+	case KindUint16:
+		return 2
+	case KindUint32:
+		return 4
+	case KindUint64:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// Format value.
+func (kind Kind) format(value []byte) string {
+	switch kind {
+	case KindByte:
+		return strconv.FormatUint(uint64(value[0]), 10)
+	case KindUint16:
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint16(value)), 10)
+	case KindUint32:
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(value)), 10)
+	case KindUint64:
+		return strconv.FormatUint(binary.LittleEndian.Uint64(value), 10)
+	default:
+		return hex.EncodeToString(value)
+	}
+}
+
+// Parse value and return false if it is invalid.
+func (kind Kind) parse(s string) ([]byte, bool) {
+	var value []byte
+	switch kind {
+	case KindBytes:
+		buffer, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, false
+		}
+		value = buffer
+	case KindByte:
+		number, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return nil, false
+		}
+		value = []byte{byte(number)}
+	case KindUint16:
+		number, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return nil, false
+		}
+		value = make([]byte, 2)
+		binary.LittleEndian.PutUint16(value, uint16(number))
+	case KindUint32:
+		number, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, false
+		}
+		value = make([]byte, 4)
+		binary.LittleEndian.PutUint32(value, uint32(number))
+	case KindUint64:
+		number, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		value = make([]byte, 8)
+		binary.LittleEndian.PutUint64(value, number)
+	default:
+		return nil, false
+	}
+	return value, true
+}
@@ -0,0 +1,119 @@
+package stateholder
+
+import (
+	"encoding/binary"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+// Active copy pointer size.
+// In double-buffered layout pointer follows header and is followed by two copies of data,
+// it is incremented on every commit and its parity selects active copy.
+const pointerSize = 8
+
+// Get size of mapped region.
+func regionSize(buffered bool, size syspack.Size) syspack.Size {
+	if buffered {
+		return headerSize + pointerSize + 2*size
+	}
+	return headerSize + size
+}
+
+// Get offset of data copy selected by pointer.
+func dataOffset(buffered bool, pointer uint64, size syspack.Size) syspack.Offset {
+	if buffered {
+		return headerSize + pointerSize + syspack.Offset(pointer%2)*syspack.Offset(size)
+	}
+	return headerSize
+}
+
+// Read active copy pointer.
+func (sh *Stateholder) pointer() (uint64, error) {
+	if !sh.buffered {
+		return 0, nil
+	}
+	buffer := make([]byte, pointerSize)
+	if n, err := sh.readRegion(buffer, headerSize); err != nil {
+		return 0, err
+	} else if n != pointerSize {
+		return 0, &ErrorCorruptedRead{Real: n, Expected: pointerSize}
+	}
+	return binary.LittleEndian.Uint64(buffer), nil
+}
+
+// Get offset of active data copy.
+func (sh *Stateholder) active() (syspack.Offset, error) {
+	pointer, err := sh.pointer()
+	if err != nil {
+		return 0, err
+	}
+	return dataOffset(sh.buffered, pointer, sh.size), nil
+}
+
+// Copy active data to inactive copy and return its pointer.
+func (sh *Stateholder) prepareCopy() (uint64, error) {
+	pointer, err := sh.pointer()
+	if err != nil {
+		return 0, err
+	}
+	buffer := make([]byte, sh.size)
+	if n, err := sh.readRegion(buffer, dataOffset(true, pointer, sh.size)); err != nil {
+		return 0, err
+	} else if n != len(buffer) {
+		return 0, &ErrorCorruptedRead{Real: n, Expected: len(buffer)}
+	}
+	if n, err := sh.writeAt(buffer, dataOffset(true, pointer+1, sh.size)); err != nil {
+		return 0, err
+	} else if n != len(buffer) {
+		return 0, &ErrorCorruptedWrite{Real: n, Expected: len(buffer)}
+	}
+	return pointer + 1, nil
+}
+
+// Sync inactive copy and make it active.
+func (sh *Stateholder) flip(pointer uint64) error {
+	if err := sh.sync(); err != nil {
+		return err
+	}
+	buffer := make([]byte, pointerSize)
+	binary.LittleEndian.PutUint64(buffer, pointer)
+	if n, err := sh.writeAt(buffer, headerSize); err != nil {
+		return err
+	} else if n != pointerSize {
+		return &ErrorCorruptedWrite{Real: n, Expected: pointerSize}
+	}
+	return nil
+}
+
+// Check that attached file was not grown by other process since it was attached.
+// Caller must hold file lock.
+func (sh *Stateholder) checkFile() error {
+	if sh.file == nil {
+		return nil
+	}
+	// Memory backend is not affected by changes of file.
+	if _, ok := sh.backend.(memoryBackend); ok {
+		return nil
+	}
+	info, err := sh.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != int64(len(sh.sign))+int64(regionSize(sh.buffered, sh.size)) {
+		return &ErrorBadFile{Path: sh.path}
+	}
+	return nil
+}
+
+// Read region data and check that file was not grown by other process meanwhile.
+// File is resized before its data is moved, so data read before successful check is valid.
+func (sh *Stateholder) readRegion(buffer []byte, offset syspack.Offset) (int, error) {
+	n, err := sh.backend.ReadAt(buffer, offset)
+	if err != nil {
+		return n, err
+	}
+	if err := sh.checkFile(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
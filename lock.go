@@ -0,0 +1,71 @@
+package stateholder
+
+import "context"
+
+// Acquire stateholder lock and exclusive lock of attached file.
+// File lock serializes writers of all processes sharing the file.
+func (sh *Stateholder) lock() error {
+	sh.mutex.Lock()
+	if sh.file != nil {
+		if err := lockFile(sh.file); err != nil {
+			sh.mutex.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// Release file lock and stateholder lock.
+func (sh *Stateholder) unlock() {
+	if sh.file != nil {
+		unlockFile(sh.file)
+	}
+	sh.mutex.Unlock()
+}
+
+// Acquire stateholder lock and exclusive lock of attached file or fail when context is done.
+func (sh *Stateholder) lockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return acquireContext(ctx, sh.lock, func(err error) {
+		if err == nil {
+			sh.unlock()
+		}
+	})
+}
+
+// Acquire stateholder read lock or fail when context is done.
+func (sh *Stateholder) rlockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return acquireContext(ctx, func() error {
+		sh.mutex.RLock()
+		return nil
+	}, func(error) {
+		sh.mutex.RUnlock()
+	})
+}
+
+// Acquire lock in background and wait for it until context is done.
+// Waiting lock keeps its place in queue, so writers are not starved by readers.
+// When context is done first, abandon is called in background with result of lock.
+func acquireContext(ctx context.Context, lock func() error, abandon func(error)) error {
+	if ctx.Done() == nil {
+		return lock()
+	}
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- lock()
+	}()
+	select {
+	case err := <-acquired:
+		return err
+	case <-ctx.Done():
+		go func() {
+			abandon(<-acquired)
+		}()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !darwin && !freebsd && !linux && !openbsd
+// +build !darwin,!freebsd,!linux,!openbsd
+
+package stateholder
+
+import "os"
+
+// Acquire exclusive file lock.
+// File locking is not supported on this platform, so writes are not serialized between processes.
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// Release file lock.
+func unlockFile(file *os.File) error {
+	return nil
+}
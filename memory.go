@@ -0,0 +1,66 @@
+package stateholder
+
+import "os"
+
+// Attach anonymous memory instead of file.
+func (sh *Stateholder) AttachMemory() error {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	if sh.index == nil {
+		return &ErrorClosed{}
+	}
+	if sh.backend != nil {
+		return &ErrorAttached{}
+	}
+	sh.sign = sh.defaultSign(false)
+	backend := NewMemoryBackend(regionSize(false, sh.size))
+	copy(backend.Bytes(), sh.initialRegion(false))
+	sh.backend = backend
+	sh.buffered = false
+	sh.readOnly = false
+	sh.path = ""
+	sh.factory = nil
+	sh.policy = SyncNever
+	return nil
+}
+
+// Write snapshot of committed data to file which can be attached later.
+// File is replaced atomically.
+func (sh *Stateholder) FlushTo(filePath string) error {
+	tempPath := filePath + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := sh.Snapshot(file); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, filePath)
+}
+
+// Restore data from file written by FlushTo or attached before.
+func (sh *Stateholder) LoadFrom(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := sh.Restore(file); err != nil {
+		if _, ok := err.(*ErrorBadSnapshot); ok {
+			return &ErrorBadFile{Path: filePath}
+		}
+		return err
+	}
+	return nil
+}
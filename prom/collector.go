@@ -0,0 +1,140 @@
+// Package prom exposes integer stateholder entries as Prometheus metrics.
+package prom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/alexeymaximov/stateholder"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric type.
+type Type byte
+
+// Available metric types.
+const (
+	Gauge Type = iota
+	Counter
+)
+
+// Metric mapping.
+type Metric struct {
+	// Metric name, entry key is used by default.
+	Name string
+
+	// Help string.
+	Help string
+
+	// Metric type.
+	Type Type
+
+	// Constant labels.
+	Labels prometheus.Labels
+}
+
+// Collector options.
+type Options struct {
+	// Metric namespace.
+	Namespace string
+
+	// Metric mappings by entry key.
+	Metrics map[string]Metric
+
+	// Expose mapped entries only.
+	MappedOnly bool
+}
+
+type Collector struct {
+	// Collector.
+
+	// Stateholder.
+	sh *stateholder.Stateholder
+
+	// Options.
+	options Options
+
+	// Descriptions.
+	descs map[string]*prometheus.Desc
+}
+
+// Make new collector.
+// Entries must be defined before collector creation.
+func NewCollector(sh *stateholder.Stateholder, options *Options) *Collector {
+	collector := &Collector{sh: sh, descs: make(map[string]*prometheus.Desc)}
+	if options != nil {
+		collector.options = *options
+	}
+	for _, key := range sh.Keys() {
+		info, err := sh.Describe(key)
+		if err != nil || info.Kind == stateholder.KindBytes {
+			continue
+		}
+		metric, ok := collector.options.Metrics[key]
+		if !ok && collector.options.MappedOnly {
+			continue
+		}
+		name := metric.Name
+		if name == "" {
+			name = sanitize(key)
+		}
+		help := metric.Help
+		if help == "" {
+			help = fmt.Sprintf("Stateholder entry %q.", key)
+		}
+		collector.descs[key] = prometheus.NewDesc(
+			prometheus.BuildFQName(collector.options.Namespace, "", name), help, nil, metric.Labels,
+		)
+	}
+	return collector
+}
+
+// Replace characters which are invalid in metric name.
+func sanitize(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// Send descriptions of metrics.
+func (collector *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range collector.descs {
+		ch <- desc
+	}
+}
+
+// Send metrics.
+func (collector *Collector) Collect(ch chan<- prometheus.Metric) {
+	err := collector.sh.Range(func(key string, info stateholder.EntryInfo, value []byte) bool {
+		desc, ok := collector.descs[key]
+		if !ok {
+			return true
+		}
+		var number float64
+		switch info.Kind {
+		case stateholder.KindByte:
+			number = float64(value[0])
+		case stateholder.KindUint16:
+			number = float64(binary.LittleEndian.Uint16(value))
+		case stateholder.KindUint32:
+			number = float64(binary.LittleEndian.Uint32(value))
+		case stateholder.KindUint64:
+			number = float64(binary.LittleEndian.Uint64(value))
+		}
+		valueType := prometheus.GaugeValue
+		if collector.options.Metrics[key].Type == Counter {
+			valueType = prometheus.CounterValue
+		}
+		ch <- prometheus.MustNewConstMetric(desc, valueType, number)
+		return true
+	})
+	if err != nil {
+		for _, desc := range collector.descs {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+		}
+	}
+}
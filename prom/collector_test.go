@@ -0,0 +1,44 @@
+package prom
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alexeymaximov/stateholder"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	sh := stateholder.NewStateholder()
+	defer sh.Close()
+	sh.Define("bytes", 5)
+	sh.DefineUint64("requests.total")
+	sh.DefineByte("flag")
+	if _, err := sh.Attach(filepath.Join(t.TempDir(), "test-prom.mem"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sh.IncUint64("requests.total", 3); err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(NewCollector(sh, &Options{
+		Namespace: "test",
+		Metrics: map[string]Metric{
+			"requests.total": {Type: Counter, Labels: prometheus.Labels{"host": "local"}},
+		},
+	}))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 2 {
+		t.Fatalf("expected 2 metric families, %d found", len(families))
+	}
+	family := families[1]
+	if name := family.GetName(); name != "test_requests_total" {
+		t.Fatalf("metric name must be a %q, %q found", "test_requests_total", name)
+	}
+	if value := family.GetMetric()[0].GetCounter().GetValue(); value != 3 {
+		t.Fatalf("metric value must be a %d, %v found", 3, value)
+	}
+}
@@ -3,18 +3,11 @@ package stateholder
 import "encoding/binary"
 
 // Set entry.
-func (sh *Stateholder) set(key string, kind Kind, value []byte) error {
-	if sh.index == nil {
-		return &ErrorClosed{}
+func set(a accessor, key string, kind Kind, value []byte) error {
+	entry, err := a.lookup(key)
+	if err != nil {
+		return err
 	}
-	if sh.mapping == nil {
-		return &ErrorDetached{}
-	}
-	index, ok := sh.index[key]
-	if !ok {
-		return &ErrorUndefined{Key: key}
-	}
-	entry := sh.entries[index]
 	if kind != entry.kind {
 		return &ErrorIncompatibleKind{Key: key, Kind: entry.kind, GivenKind: kind}
 	}
@@ -22,36 +15,161 @@ func (sh *Stateholder) set(key string, kind Kind, value []byte) error {
 	if valueSize != entry.size {
 		return &ErrorIncompatibleSize{Key: key, Size: entry.size, GivenSize: valueSize}
 	}
-	return sh.write(entry, value)
+	return a.write(entry, value)
 }
 
 // Set byte array.
 func (sh *Stateholder) Set(key string, value []byte) error {
-	return sh.set(key, KindBytes, value)
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return set(sh, key, KindBytes, value)
+}
+
+// Set byte array within transaction.
+func (tx *Tx) Set(key string, value []byte) error {
+	return set(tx, key, KindBytes, value)
 }
 
 // Set byte.
 func (sh *Stateholder) SetByte(key string, value byte) error {
-	return sh.set(key, KindByte, []byte{value})
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return set(sh, key, KindByte, []byte{value})
+}
+
+// Set byte within transaction.
+func (tx *Tx) SetByte(key string, value byte) error {
+	return set(tx, key, KindByte, []byte{value})
 }
 
 // Set 16-bit unsigned integer value.
-func (sh *Stateholder) SetUint16(key string, value uint16) error {
+func setUint16(a accessor, key string, value uint16) error {
 	buffer := make([]byte, 2)
 	binary.LittleEndian.PutUint16(buffer, value)
-	return sh.set(key, KindUint16, buffer)
+	return set(a, key, KindUint16, buffer)
+}
+
+// Set 16-bit unsigned integer value.
+func (sh *Stateholder) SetUint16(key string, value uint16) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return setUint16(sh, key, value)
+}
+
+// Set 16-bit unsigned integer value within transaction.
+func (tx *Tx) SetUint16(key string, value uint16) error {
+	return setUint16(tx, key, value)
 }
 
 // Set 32-bit unsigned integer value.
-func (sh *Stateholder) SetUint32(key string, value uint32) error {
+func setUint32(a accessor, key string, value uint32) error {
 	buffer := make([]byte, 4)
 	binary.LittleEndian.PutUint32(buffer, value)
-	return sh.set(key, KindUint32, buffer)
+	return set(a, key, KindUint32, buffer)
+}
+
+// Set 32-bit unsigned integer value.
+func (sh *Stateholder) SetUint32(key string, value uint32) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return setUint32(sh, key, value)
+}
+
+// Set 32-bit unsigned integer value within transaction.
+func (tx *Tx) SetUint32(key string, value uint32) error {
+	return setUint32(tx, key, value)
 }
 
 // Set 64-bit unsigned integer value.
-func (sh *Stateholder) SetUint64(key string, value uint64) error {
+func setUint64(a accessor, key string, value uint64) error {
 	buffer := make([]byte, 8)
 	binary.LittleEndian.PutUint64(buffer, value)
-	return sh.set(key, KindUint64, buffer)
+	return set(a, key, KindUint64, buffer)
+}
+
+// Set 64-bit unsigned integer value.
+func (sh *Stateholder) SetUint64(key string, value uint64) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return setUint64(sh, key, value)
+}
+
+// Set 64-bit unsigned integer value within transaction.
+func (tx *Tx) SetUint64(key string, value uint64) error {
+	return setUint64(tx, key, value)
+}
+
+// Set value parsed according to its kind.
+func setString(a accessor, key string, s string) error {
+	entry, err := a.lookup(key)
+	if err != nil {
+		return err
+	}
+	value, ok := entry.kind.parse(s)
+	if !ok {
+		return &ErrorInvalidValue{Key: key, Value: s}
+	}
+	return set(a, key, entry.kind, value)
+}
+
+// Set value parsed according to its kind.
+// Integer values are parsed as decimal numbers and byte arrays as hexadecimal strings.
+func (sh *Stateholder) SetString(key string, s string) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return setString(sh, key, s)
+}
+
+// Set value parsed according to its kind within transaction.
+func (tx *Tx) SetString(key string, s string) error {
+	return setString(tx, key, s)
+}
+
+// Copy entry.
+func copyEntry(a accessor, key, sourceKey string) error {
+	entry, err := a.lookup(key)
+	if err != nil {
+		return err
+	}
+	sourceEntry, err := a.lookup(sourceKey)
+	if err != nil {
+		return err
+	}
+	if entry.kind != sourceEntry.kind {
+		return &ErrorIncompatibleKind{Key: key, Kind: entry.kind, GivenKind: sourceEntry.kind}
+	}
+	if entry.size != sourceEntry.size {
+		return &ErrorIncompatibleSize{Key: key, Size: entry.size, GivenSize: sourceEntry.size}
+	}
+	value, err := a.read(sourceEntry)
+	if err != nil {
+		return err
+	}
+	return a.write(entry, value)
+}
+
+// Copy entry.
+func (sh *Stateholder) Copy(key, sourceKey string) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	return copyEntry(sh, key, sourceKey)
+}
+
+// Copy entry within transaction.
+func (tx *Tx) Copy(key, sourceKey string) error {
+	return copyEntry(tx, key, sourceKey)
 }
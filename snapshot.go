@@ -0,0 +1,98 @@
+package stateholder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+// Write snapshot of committed data.
+// Snapshot has the same layout as attached file.
+func (sh *Stateholder) Snapshot(w io.Writer) error {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		return &ErrorDetached{}
+	}
+	buffer := make([]byte, regionSize(sh.buffered, sh.size))
+	if n, err := sh.readRegion(buffer, 0); err != nil {
+		return err
+	} else if n != len(buffer) {
+		return &ErrorCorruptedRead{Real: n, Expected: len(buffer)}
+	}
+	if _, err := w.Write(sh.sign); err != nil {
+		return err
+	}
+	if _, err := w.Write(buffer); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Read active data copy from snapshot.
+func readSnapshot(r io.Reader, sign []byte, buffered bool, size syspack.Size) ([]byte, error) {
+	buffer := make([]byte, len(sign))
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &ErrorBadSnapshot{}
+		}
+		return nil, err
+	}
+	if bytes.Compare(buffer, sign) != 0 {
+		return nil, &ErrorBadSnapshot{}
+	}
+	buffer = make([]byte, regionSize(buffered, size))
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &ErrorBadSnapshot{}
+		}
+		return nil, err
+	}
+	var pointer uint64
+	if buffered {
+		pointer = binary.LittleEndian.Uint64(buffer[headerSize:])
+	}
+	offset := dataOffset(buffered, pointer, size)
+	return buffer[offset : offset+syspack.Offset(size)], nil
+}
+
+// Restore data from snapshot.
+func (sh *Stateholder) Restore(r io.Reader) error {
+	return sh.Update(func(tx *Tx) error {
+		return tx.Restore(r)
+	})
+}
+
+// Restore data from snapshot within transaction.
+func (tx *Tx) Restore(r io.Reader) error {
+	if tx.done {
+		return &ErrorTransactionFinished{}
+	}
+	sh := tx.sh
+	sh.mutex.RLock()
+	if sh.index == nil {
+		sh.mutex.RUnlock()
+		return &ErrorClosed{}
+	}
+	if sh.backend == nil {
+		sh.mutex.RUnlock()
+		return &ErrorDetached{}
+	}
+	sign, buffered, size, entries := sh.sign, sh.buffered, sh.size, sh.entries
+	sh.mutex.RUnlock()
+	data, err := readSnapshot(r, sign, buffered, size)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := tx.write(entry, data[entry.offset:entry.offset+syspack.Offset(entry.size)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
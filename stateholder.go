@@ -1,20 +1,25 @@
 package stateholder
 
-// TODO: Use direct mapping slice for integer entries reading.
+// TODO: Use direct backend memory for integer entries reading.
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"github.com/alexeymaximov/syspack"
-	"github.com/alexeymaximov/syspack/mmap"
 )
 
 type Stateholder struct {
 	// Stateholder.
 
+	// Mutex.
+	mutex sync.RWMutex
+
 	// Index.
 	index map[string]int
 
@@ -24,11 +29,50 @@ type Stateholder struct {
 	// Size.
 	size syspack.Size
 
-	// Mapping.
-	mapping *mmap.Mapping
+	// Signature.
+	sign []byte
+
+	// Backend.
+	backend Backend
+
+	// Attached file which is locked by writers.
+	file *os.File
+
+	// Whether data is double-buffered.
+	buffered bool
+
+	// Whether file is attached read-only.
+	readOnly bool
+
+	// Attached file path, it is empty for anonymous memory.
+	path string
+
+	// Backend factory used to attach file.
+	factory BackendFactory
+
+	// Watchers.
+	watchers watchers
+
+	// Number of open transactions.
+	transactions int64
 
-	// Transaction mode.
-	transaction bool
+	// Pages written since last sync.
+	dirty dirtyPages
+
+	// Sync policy.
+	policy SyncPolicy
+
+	// Number of writes and commits since last sync.
+	pending int
+
+	// Background sync stop channel.
+	stopSync chan struct{}
+
+	// Background sync error mutex.
+	syncMutex sync.Mutex
+
+	// Last background sync error.
+	syncErr error
 }
 
 // Make new stateholder.
@@ -40,10 +84,17 @@ func NewStateholder() *Stateholder {
 
 // Read entry.
 func (sh *Stateholder) read(entry *entry) ([]byte, error) {
+	base, err := sh.active()
+	if err != nil {
+		return nil, err
+	}
+	return sh.readAt(base, entry)
+}
+
+// Read entry from data copy at given offset.
+func (sh *Stateholder) readAt(base syspack.Offset, entry *entry) ([]byte, error) {
 	value := make([]byte, entry.size)
-	if sh.transaction && entry.buffer != nil {
-		copy(value, entry.buffer)
-	} else if n, err := sh.mapping.ReadAt(value, entry.offset); err != nil {
+	if n, err := sh.readRegion(value, base+entry.offset); err != nil {
 		return nil, err
 	} else if n != int(entry.size) {
 		return nil, &ErrorCorruptedRead{Real: n, Expected: int(entry.size)}
@@ -51,150 +102,419 @@ func (sh *Stateholder) read(entry *entry) ([]byte, error) {
 	return value, nil
 }
 
-// Write entry.
-func (sh *Stateholder) write(entry *entry, value []byte) error {
-	if sh.transaction {
-		if entry.buffer == nil {
-			entry.buffer = make([]byte, entry.size)
+// Read entry version.
+func (sh *Stateholder) version(entry *entry) (uint64, error) {
+	base, err := sh.active()
+	if err != nil {
+		return 0, err
+	}
+	return sh.versionAt(base, entry)
+}
+
+// Read entry version from data copy at given offset.
+func (sh *Stateholder) versionAt(base syspack.Offset, entry *entry) (uint64, error) {
+	buffer := make([]byte, versionSize)
+	if n, err := sh.readRegion(buffer, base+entry.offset-versionSize); err != nil {
+		return 0, err
+	} else if n != versionSize {
+		return 0, &ErrorCorruptedRead{Real: n, Expected: versionSize}
+	}
+	return binary.LittleEndian.Uint64(buffer), nil
+}
+
+// Store entry to data copy at given offset and return change if entry is watched and modified.
+func (sh *Stateholder) store(base syspack.Offset, entry *entry, value []byte) (*Change, error) {
+	var old []byte
+	if sh.watchers.watched() {
+		var err error
+		if old, err = sh.readAt(base, entry); err != nil {
+			return nil, err
 		}
-		copy(entry.buffer, value)
-	} else if n, err := sh.mapping.WriteAt(value, entry.offset); err != nil {
-		return err
+	}
+	version, err := sh.versionAt(base, entry)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := sh.writeAt(value, base+entry.offset); err != nil {
+		return nil, err
 	} else if n != int(entry.size) {
-		return &ErrorCorruptedWrite{Real: n, Expected: int(entry.size)}
+		return nil, &ErrorCorruptedWrite{Real: n, Expected: int(entry.size)}
 	}
-	return nil
+	buffer := make([]byte, versionSize)
+	binary.LittleEndian.PutUint64(buffer, version+1)
+	if n, err := sh.writeAt(buffer, base+entry.offset-versionSize); err != nil {
+		return nil, err
+	} else if n != versionSize {
+		return nil, &ErrorCorruptedWrite{Real: n, Expected: versionSize}
+	}
+	if old == nil || bytes.Compare(old, value) == 0 {
+		return nil, nil
+	}
+	return &Change{Key: entry.key, Kind: entry.kind, Old: old, New: append([]byte(nil), value...)}, nil
 }
 
-// Copy entry.
-func (sh *Stateholder) Copy(key, sourceKey string) error {
-	if sh.index == nil {
-		return &ErrorClosed{}
+// Apply writes in definition order within sequence lock and return changes.
+// Caller must hold file lock.
+// In double-buffered layout writes are applied to inactive copy which becomes active on success,
+// so nothing is applied on failure.
+func (sh *Stateholder) apply(writes map[*entry][]byte) ([]Change, error) {
+	if len(writes) == 0 {
+		return nil, nil
 	}
-	if sh.mapping == nil {
-		return &ErrorDetached{}
-	}
-	var index int
-	var ok bool
-	index, ok = sh.index[key]
-	if !ok {
-		return &ErrorUndefined{Key: key}
+	if sh.readOnly {
+		return nil, &ErrorReadOnly{}
 	}
-	entry := sh.entries[index]
-	index, ok = sh.index[sourceKey]
-	if !ok {
-		return &ErrorUndefined{Key: sourceKey}
+	if err := sh.checkFile(); err != nil {
+		return nil, err
 	}
-	sourceEntry := sh.entries[index]
-	if entry.kind != sourceEntry.kind {
-		return &ErrorIncompatibleKind{Key: key, Kind: entry.kind, GivenKind: sourceEntry.kind}
+	if err := sh.beginWrite(); err != nil {
+		return nil, err
 	}
-	if entry.size != sourceEntry.size {
-		return &ErrorIncompatibleSize{Key: key, Size: entry.size, GivenSize: sourceEntry.size}
+	changes, err := sh.applyCopy(writes)
+	// Sequence number left odd when it fails is repaired by next write or attach.
+	if endErr := sh.endWrite(); err == nil {
+		err = endErr
 	}
-	value, err := sh.read(sourceEntry)
 	if err != nil {
-		return err
+		return changes, err
 	}
-	return sh.write(entry, value)
+	return changes, sh.syncOnWrite()
 }
 
-// Prepare file.
-func (sh *Stateholder) prepareFile(file *os.File, sign []byte) error {
-	signLen := len(sign)
-	if err := file.Truncate(int64(syspack.Size(signLen) + sh.size)); err != nil {
-		return err
+// Apply writes to data copy.
+func (sh *Stateholder) applyCopy(writes map[*entry][]byte) ([]Change, error) {
+	var pointer uint64
+	if sh.buffered {
+		var err error
+		if pointer, err = sh.prepareCopy(); err != nil {
+			return nil, err
+		}
 	}
-	if signLen > 0 {
-		buffer := make([]byte, syspack.Size(signLen))
-		copy(buffer[:signLen], sign)
-		if n, err := file.WriteAt(buffer, 0); err != nil {
-			return err
-		} else if n != signLen {
-			return &ErrorBadFile{Path: file.Name()}
+	base := dataOffset(sh.buffered, pointer, sh.size)
+	var changes []Change
+	for _, entry := range sh.entries {
+		value, ok := writes[entry]
+		if !ok {
+			continue
+		}
+		change, err := sh.store(base, entry, value)
+		if err != nil {
+			if sh.buffered {
+				return nil, err
+			}
+			return changes, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
 		}
 	}
+	if sh.buffered {
+		if err := sh.flip(pointer); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// Write entry.
+func (sh *Stateholder) write(e *entry, value []byte) error {
+	changes, err := sh.apply(map[*entry][]byte{e: value})
+	sh.watchers.notify(changes)
+	return err
+}
+
+// Make initial region filled with default values.
+func (sh *Stateholder) initialRegion(buffered bool) []byte {
+	region := make([]byte, regionSize(buffered, sh.size))
+	copies := 1
+	if buffered {
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		base := dataOffset(buffered, uint64(i), sh.size)
+		for _, entry := range sh.entries {
+			copy(region[base+entry.offset:], entry.initial)
+		}
+	}
+	return region
+}
+
+// Prepare file.
+func (sh *Stateholder) prepareFile(file *os.File, sign []byte, region []byte) error {
+	buffer := append(append([]byte(nil), sign...), region...)
+	if n, err := file.WriteAt(buffer, 0); err != nil {
+		return err
+	} else if n != len(buffer) {
+		return &ErrorBadFile{Path: file.Name()}
+	}
 	if err := file.Sync(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Attach options.
+type Options struct {
+	// File signature, it is generated from entry definitions by default.
+	// Format 3.0.0 stores 8-byte version before every entry, so file is 8 bytes
+	// per entry larger than file of format 2.0.0. Files of formats 1.0.0 and 2.0.0
+	// with default signature are upgraded to format 3.0.0 in place on attach
+	// unless attached read-only.
+	// File which size does not match signature and entry definitions
+	// is rejected with ErrorBadFile even if custom signature is given.
+	Sign []byte
+
+	// Backend factory, MmapBackend is used by default.
+	Backend BackendFactory
+
+	// Keep two copies of data and switch between them on commit
+	// to make writes and commits atomic in case of crash.
+	DoubleBuffered bool
+
+	// Durability policy, SyncNever is used by default.
+	// Background sync is stopped on Close and its errors are available via SyncError.
+	Sync SyncPolicy
+
+	// Attach existing file without modifying it, writes fail with ErrorReadOnly.
+	// File is neither created nor locked on attach and change sequence number
+	// left odd by interrupted write is not repaired.
+	ReadOnly bool
+}
+
+// Make default signature.
+func (sh *Stateholder) defaultSign(buffered bool) []byte {
+	sign := []byte{'M', 'E', 'M', 3, 0, 0}
+	if buffered {
+		sign[2] = 'D'
+	}
+	entrySign := make([]byte, signEntrySize)
+	for _, entry := range sh.entries {
+		entrySign[0] = byte(entry.kind)
+		binary.LittleEndian.PutUint16(entrySign[1:], entry.size)
+		sign = append(sign, entrySign...)
+	}
+	return sign
+}
+
 // Attach file and return true is new file was created.
+// New file is initialized with default values of entries.
 func (sh *Stateholder) Attach(filePath string, sign []byte) (bool, error) {
+	return sh.AttachWithOptions(filePath, &Options{Sign: sign})
+}
+
+// Attach file with options and return true is new file was created.
+func (sh *Stateholder) AttachWithOptions(filePath string, options *Options) (bool, error) {
+	return sh.AttachContext(context.Background(), filePath, options)
+}
+
+// Attach file with options and return true is new file was created.
+// It fails when context is done before stateholder lock and file lock are acquired.
+// File is locked while it is created or checked, so concurrent processes initialize it once.
+func (sh *Stateholder) AttachContext(ctx context.Context, filePath string, options *Options) (bool, error) {
+	if err := sh.lockContext(ctx); err != nil {
+		return false, err
+	}
+	defer sh.unlock()
 	if sh.index == nil {
 		return false, &ErrorClosed{}
 	}
-	if sh.mapping != nil {
+	if sh.backend != nil {
 		return false, &ErrorAttached{}
 	}
+	if options == nil {
+		options = &Options{}
+	}
+	sign := options.Sign
 	if sign == nil {
-		sign = []byte{'M', 'E', 'M', 1, 0, 0}
-		entrySign := make([]byte, 3)
-		for _, entry := range sh.entries {
-			entrySign[0] = byte(entry.kind)
-			binary.LittleEndian.PutUint16(entrySign[1:], entry.size)
-			sign = append(sign, entrySign...)
-		}
+		sign = sh.defaultSign(options.DoubleBuffered)
 	}
-	init := false
-	if _, err := os.Stat(filePath); err != nil && os.IsNotExist(err) {
-		init = true
+	factory := options.Backend
+	if factory == nil {
+		factory = MmapBackend
+	}
+	if options.ReadOnly {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return false, err
+		}
+		if _, err := sh.attachFile(file, sign, options, factory); err != nil {
+			file.Close()
+			return false, err
+		}
+		sh.file = file
+		return false, nil
 	}
 	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
 		return false, err
 	}
-	defer file.Close()
+	if err := acquireContext(ctx, func() error {
+		return lockFile(file)
+	}, func(error) {
+		file.Close()
+	}); err != nil {
+		// File of abandoned lock is closed in background.
+		if err != ctx.Err() {
+			file.Close()
+		}
+		return false, err
+	}
+	init, err := sh.attachFile(file, sign, options, factory)
+	if err != nil {
+		file.Close()
+		return false, err
+	}
+	sh.file = file
+	return init, nil
+}
+
+// Prepare locked file and attach it.
+func (sh *Stateholder) attachFile(file *os.File, sign []byte, options *Options, factory BackendFactory) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	init := info.Size() == 0 && !options.ReadOnly
 	if init {
-		if err := sh.prepareFile(file, sign); err != nil {
+		if err := sh.prepareFile(file, sign, sh.initialRegion(options.DoubleBuffered)); err != nil {
 			return false, err
 		}
 	}
 	signLen := len(sign)
+	size := regionSize(options.DoubleBuffered, sh.size)
+	fileSize := info.Size()
+	if !init && options.Sign == nil && !options.ReadOnly {
+		if upgraded, err := sh.upgradeFile(file, fileSize, options.DoubleBuffered); err != nil {
+			return false, err
+		} else if upgraded {
+			fileSize = int64(signLen) + int64(size)
+		}
+	}
+	if !init && fileSize != int64(signLen)+int64(size) {
+		return false, &ErrorBadFile{Path: file.Name()}
+	}
 	buffer := make([]byte, signLen)
 	if n, err := file.ReadAt(buffer, 0); err != nil {
 		return false, err
 	} else if n != signLen {
-		return false, &ErrorBadFile{Path: filePath}
+		return false, &ErrorBadFile{Path: file.Name()}
 	}
 	if bytes.Compare(buffer, sign) != 0 {
-		return false, &ErrorBadFile{Path: filePath}
+		return false, &ErrorBadFile{Path: file.Name()}
 	}
-	mapping, err := mmap.NewMapping(file.Fd(), syspack.Offset(signLen), sh.size, &mmap.Options{
-		Mode: mmap.ModeReadWrite,
-	})
+	if !options.ReadOnly {
+		if err := repairSequence(file, int64(signLen)); err != nil {
+			return false, err
+		}
+	}
+	backend, err := factory(file, syspack.Offset(signLen), size)
 	if err != nil {
 		return false, err
 	}
-	sh.mapping = mapping
+	sh.sign = sign
+	sh.backend = backend
+	sh.buffered = options.DoubleBuffered
+	sh.readOnly = options.ReadOnly
+	sh.path = file.Name()
+	sh.factory = factory
+	sh.policy = options.Sync
+	sh.pending = 0
+	sh.dirty.take()
+	sh.startSync()
 	return init, nil
 }
 
+// Make change sequence number of file even if it was left odd by interrupted write.
+func repairSequence(file *os.File, offset int64) error {
+	buffer := make([]byte, headerSize)
+	if n, err := file.ReadAt(buffer, offset); err != nil {
+		return err
+	} else if n != headerSize {
+		return &ErrorBadFile{Path: file.Name()}
+	}
+	seq := binary.LittleEndian.Uint64(buffer)
+	if seq%2 == 0 {
+		return nil
+	}
+	binary.LittleEndian.PutUint64(buffer, seq+1)
+	if n, err := file.WriteAt(buffer, offset); err != nil {
+		return err
+	} else if n != headerSize {
+		return &ErrorCorruptedWrite{Real: n, Expected: headerSize}
+	}
+	return nil
+}
+
 // Sync data.
 func (sh *Stateholder) Sync() error {
+	return sh.SyncContext(context.Background())
+}
+
+// Detach file keeping entry definitions and watchers, so file can be attached again.
+// It fails with ErrorTransactionOpen if any transaction begun is neither committed nor rolled back.
+func (sh *Stateholder) Detach() error {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
 	if sh.index == nil {
 		return &ErrorClosed{}
 	}
-	if sh.mapping == nil {
+	if sh.backend == nil {
 		return &ErrorDetached{}
 	}
-	return sh.mapping.Sync()
+	if atomic.LoadInt64(&sh.transactions) > 0 {
+		return &ErrorTransactionOpen{}
+	}
+	// Background sync is stopped after successful sync only, so it keeps running if Detach fails.
+	if err := sh.sync(); err != nil {
+		return err
+	}
+	sh.stopSyncLoop()
+	if err := sh.backend.Close(); err != nil {
+		return err
+	}
+	sh.backend = nil
+	if err := sh.closeFile(); err != nil {
+		return err
+	}
+	sh.sign = nil
+	sh.buffered = false
+	sh.readOnly = false
+	sh.path = ""
+	sh.factory = nil
+	sh.policy = SyncNever
+	return nil
+}
+
+// Close attached file releasing its lock.
+func (sh *Stateholder) closeFile() error {
+	if sh.file == nil {
+		return nil
+	}
+	err := sh.file.Close()
+	sh.file = nil
+	return err
 }
 
 // Close stateholder.
 func (sh *Stateholder) Close() error {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
 	if sh.index == nil {
 		return &ErrorClosed{}
 	}
-	if sh.mapping != nil {
-		if err := sh.mapping.Close(); err != nil {
+	sh.stopSyncLoop()
+	if sh.backend != nil {
+		if err := sh.backend.Close(); err != nil {
 			return err
 		}
-		sh.mapping = nil
+		sh.backend = nil
+	}
+	if err := sh.closeFile(); err != nil {
+		return err
 	}
 	sh.index = nil
 	sh.entries = nil
+	sh.watchers.close()
 	runtime.SetFinalizer(sh, nil)
 	return nil
 }
@@ -2,9 +2,16 @@ package stateholder
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"expvar"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 var testPath = filepath.Join(os.TempDir(), "test.mem")
@@ -144,54 +151,1423 @@ func TestTransactionCommit(t *testing.T) {
 	if _, err := stateholder.Attach(testPath, nil); err != nil {
 		t.Fatal(err)
 	}
-	if err := stateholder.Begin(); err != nil {
+	tx, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := tx.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != emptyUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", emptyUint64, value)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	if err := clearStateholder(); err != nil {
+		t.Fatal(err)
+	}
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(testPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := tx.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != emptyUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", emptyUint64, value)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	other := testStateholder()
+	defer other.Close()
+	if _, err := other.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	value, version, err := stateholder.GetWithVersion("bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.SetIfVersion("bytes", testBytes, version); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetIfVersion("bytes", value, version); err == nil {
+		t.Fatal("expected ErrorConflict, no error found")
+	} else if _, ok := err.(*ErrorConflict); !ok {
+		t.Fatalf("expected ErrorConflict, [%v] error found", err)
+	}
+	if value, newVersion, err := stateholder.GetWithVersion("bytes"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Compare(value, testBytes) != 0 {
+		t.Fatalf("bytes must be a %q, %v found", testBytes, value)
+	} else if newVersion != version+1 {
+		t.Fatalf("version must be a %d, %d found", version+1, newVersion)
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Update(func(tx *Tx) error {
+		if err := tx.SetUint64("uint64", testUint64); err != nil {
+			return err
+		}
+		if err := tx.Savepoint("bytes"); err != nil {
+			return err
+		}
+		if err := tx.Set("bytes", testBytes); err != nil {
+			return err
+		}
+		if _, _, err := tx.IncUint64("uint64", 1); err != nil {
+			return err
+		}
+		return tx.RollbackTo("bytes")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.Get("bytes"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Compare(value, emptyBytes) != 0 {
+		t.Fatalf("bytes must be a %v, %v found", emptyBytes, value)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if err := stateholder.Update(func(tx *Tx) error {
+		return tx.RollbackTo("bytes")
+	}); err == nil {
+		t.Fatal("expected ErrorUndefinedSavepoint, no error found")
+	} else if _, ok := err.(*ErrorUndefinedSavepoint); !ok {
+		t.Fatalf("expected ErrorUndefinedSavepoint, [%v] error found", err)
+	}
+}
+
+func TestTransactionConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	a, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.IncUint64("uint64", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.IncUint64("uint64", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Commit(); err == nil {
+		t.Fatal("expected ErrorConflict, no error found")
+	} else if _, ok := err.(*ErrorConflict); !ok {
+		t.Fatalf("expected ErrorConflict, [%v] error found", err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != 2 {
+		t.Fatalf("uint64 must be a %d, %d found", 2, value)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	for _, factory := range []BackendFactory{FileBackend, MemoryBackend} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		stateholder := testStateholder()
+		if _, err := stateholder.AttachWithOptions(path, &Options{Backend: factory}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := stateholder.GetUint64("uint64"); err != nil {
+			t.Fatal(err)
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		if err := stateholder.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != emptyUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", emptyUint64, value)
+	}
+}
+
+func TestDoubleBuffered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	options := &Options{DoubleBuffered: true}
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.AttachWithOptions(path, options); err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if err := a.SetUint64("uint64", testUint64*i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Set("bytes", testBytes); err != nil {
+		t.Fatal(err)
+	}
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(path, nil); err == nil {
+		t.Fatal("expected ErrorBadFile, no error found")
+	} else if _, ok := err.(*ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+	if _, err := b.AttachWithOptions(path, options); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := b.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64*3 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64*3, value)
+	}
+	if value, err := b.Get("bytes"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Compare(value, testBytes) != 0 {
+		t.Fatalf("bytes must be a %v, %v found", testBytes, value)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.mem")
+	otherPath := filepath.Join(dir, "test-detach.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Detach(); err == nil {
+		t.Fatal("expected ErrorTransactionOpen, no error found")
+	} else if _, ok := err.(*ErrorTransactionOpen); !ok {
+		t.Fatalf("expected ErrorTransactionOpen, [%v] error found", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stateholder.GetUint64("uint64"); err == nil {
+		t.Fatal("expected ErrorDetached, no error found")
+	} else if _, ok := err.(*ErrorDetached); !ok {
+		t.Fatalf("expected ErrorDetached, [%v] error found", err)
+	}
+	if init, err := stateholder.Attach(otherPath, nil); err != nil {
+		t.Fatal(err)
+	} else if !init {
+		t.Fatal("new file must be created")
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != emptyUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", emptyUint64, value)
+	}
+	if err := stateholder.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+}
+
+func TestDefineLive(t *testing.T) {
+	for _, options := range []*Options{{}, {DoubleBuffered: true}} {
+		path := filepath.Join(t.TempDir(), "test.mem")
+		stateholder := testStateholder()
+		if _, err := stateholder.AttachWithOptions(path, options); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.DefineLive("weird", Kind(42), 4); err == nil {
+			t.Fatal("expected ErrorInvalidKind, no error found")
+		} else if _, ok := err.(*ErrorInvalidKind); !ok {
+			t.Fatalf("expected ErrorInvalidKind, [%v] error found", err)
+		}
+		if err := stateholder.DefineLive("uint32", KindUint32, 8); err == nil {
+			t.Fatal("expected ErrorInvalidSize, no error found")
+		} else if _, ok := err.(*ErrorInvalidSize); !ok {
+			t.Fatalf("expected ErrorInvalidSize, [%v] error found", err)
+		}
+		if err := stateholder.DefineLive("uint32", KindUint32, 4); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.SetUint32("uint32", uint32(testUint64)); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := stateholder.GetUint64("uint64"); err != nil {
+			t.Fatal(err)
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		if err := stateholder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		stateholder = testStateholder()
+		stateholder.DefineUint32("uint32")
+		if _, err := stateholder.AttachWithOptions(path, options); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := stateholder.GetUint32("uint32"); err != nil {
+			t.Fatal(err)
+		} else if value != uint32(testUint64) {
+			t.Fatalf("uint32 must be a %d, %d found", testUint64, value)
+		}
+		if value, err := stateholder.GetUint64("uint64"); err != nil {
+			t.Fatal(err)
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		if err := stateholder.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, []byte("CUSTOM")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.DefineLive("uint32", KindUint32, 4); err == nil {
+		t.Fatal("expected ErrorAttached, no error found")
+	} else if _, ok := err.(*ErrorAttached); !ok {
+		t.Fatalf("expected ErrorAttached, [%v] error found", err)
+	}
+}
+
+func TestDefineLiveBackends(t *testing.T) {
+	for _, backend := range []struct {
+		factory BackendFactory
+		memory  bool
+	}{{FileBackend, false}, {MemoryBackend, true}, {MmapBackend, false}} {
+		path := filepath.Join(t.TempDir(), "test.mem")
+		stateholder := testStateholder()
+		if _, err := stateholder.AttachWithOptions(path, &Options{Backend: backend.factory}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.Set("bytes", testBytes); err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+			t.Fatal(err)
+		}
+		before, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stateholder.DefineLive("uint32", KindUint32, 4, WithDefault(7)); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := stateholder.Get("bytes"); err != nil {
+			t.Fatal(err)
+		} else if bytes.Compare(value, testBytes) != 0 {
+			t.Fatalf("bytes must be a %v, %v found", testBytes, value)
+		}
+		if value, err := stateholder.GetUint64("uint64"); err != nil {
+			t.Fatal(err)
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		if value, err := stateholder.GetUint32("uint32"); err != nil {
+			t.Fatal(err)
+		} else if value != 7 {
+			t.Fatalf("uint32 must be a %d, %d found", 7, value)
+		}
+		if err := stateholder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		after, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if backend.memory {
+			if bytes.Compare(after, before) != 0 {
+				t.Fatal("file attached with memory backend must be left intact")
+			}
+			continue
+		}
+		stateholder = testStateholder()
+		stateholder.DefineUint32("uint32")
+		if _, err := stateholder.Attach(path, nil); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := stateholder.GetUint64("uint64"); err != nil {
+			t.Fatal(err)
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		if value, err := stateholder.GetUint32("uint32"); err != nil {
+			t.Fatal(err)
+		} else if value != 7 {
+			t.Fatalf("uint32 must be a %d, %d found", 7, value)
+		}
+		if err := stateholder.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDefineLiveShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := a.Sequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DefineLive("uint32", KindUint32, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetUint32("uint32", uint32(testUint64)); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Fatal("file must be grown in place")
+	}
+	if value, err := a.Sequence(); err != nil {
+		t.Fatal(err)
+	} else if value != seq+4 {
+		t.Fatalf("sequence must be a %d, %d found", seq+4, value)
+	}
+	if _, err := b.GetUint64("uint64"); err == nil {
+		t.Fatal("expected ErrorBadFile, nil error found")
+	} else if _, ok := err.(*ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+	if _, err := b.ReadConsistent("uint64"); err == nil {
+		t.Fatal("expected ErrorBadFile, nil error found")
+	} else if _, ok := err.(*ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+	if _, err := b.Sequence(); err == nil {
+		t.Fatal("expected ErrorBadFile, nil error found")
+	} else if _, ok := err.(*ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+	if err := b.SetUint64("uint64", testUint64); err == nil {
+		t.Fatal("expected ErrorBadFile, nil error found")
+	} else if _, ok := err.(*ErrorBadFile); !ok {
+		t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+	}
+	if err := b.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	b.DefineUint32("uint32")
+	if _, err := b.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := b.GetUint32("uint32"); err != nil {
+		t.Fatal(err)
+	} else if value != uint32(testUint64) {
+		t.Fatalf("uint32 must be a %d, %d found", testUint64, value)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := NewStateholder()
+	defer stateholder.Close()
+	if err := stateholder.DefineByte("byte", WithDefault(300)); err == nil {
+		t.Fatal("expected ErrorInvalidValue, no error found")
+	} else if _, ok := err.(*ErrorInvalidValue); !ok {
+		t.Fatalf("expected ErrorInvalidValue, [%v] error found", err)
+	}
+	if err := stateholder.Define("bytes", uint16(len(testBytes)), WithDefault("HE")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.DefineUint64("uint64", WithDefault(testUint64)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.Get("bytes"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Compare(value, []byte{'H', 'E', 0, 0, 0}) != 0 {
+		t.Fatalf("bytes must be a %v, %v found", []byte{'H', 'E', 0, 0, 0}, value)
+	}
+	if err := stateholder.DefineLive("uint32", KindUint32, 4, WithDefault("7")); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint32("uint32"); err != nil {
+		t.Fatal(err)
+	} else if value != 7 {
+		t.Fatalf("uint32 must be a %d, %d found", 7, value)
+	}
+	if err := stateholder.SetUint64("uint64", emptyUint64); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetUint32("uint32", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Reset("uint64"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if err := stateholder.Set("bytes", testBytes); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.ResetAll(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint32("uint32"); err != nil {
+		t.Fatal(err)
+	} else if value != 7 {
+		t.Fatalf("uint32 must be a %d, %d found", 7, value)
+	}
+	if value, err := stateholder.Get("bytes"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Compare(value, []byte{'H', 'E', 0, 0, 0}) != 0 {
+		t.Fatalf("bytes must be a %v, %v found", []byte{'H', 'E', 0, 0, 0}, value)
+	}
+}
+
+func TestAttachMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if err := stateholder.AttachMemory(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := stateholder.IncUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.FlushTo(path); err != nil {
+		t.Fatal(err)
+	}
+	other := testStateholder()
+	defer other.Close()
+	if _, err := other.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := other.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if err := other.SetUint64("uint64", emptyUint64); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.LoadFrom(path); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != emptyUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", emptyUint64, value)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Update(func(tx *Tx) error {
+		return tx.SetUint64("uint64", testUint64)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic, no panic found")
+			}
+		}()
+		stateholder.Update(func(tx *Tx) error {
+			if _, _, err := tx.IncUint64("uint64", 1); err != nil {
+				return err
+			}
+			panic("update")
+		})
+	}()
+	if err := stateholder.View(func(tx *Tx) error {
+		if value, err := tx.GetUint64("uint64"); err != nil {
+			return err
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		return tx.SetUint64("uint64", emptyUint64)
+	}); err == nil {
+		t.Fatal("expected ErrorReadOnly, no error found")
+	} else if _, ok := err.(*ErrorReadOnly); !ok {
+		t.Fatalf("expected ErrorReadOnly, [%v] error found", err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := new(bytes.Buffer)
+	if err := stateholder.Snapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetUint64("uint64", emptyUint64); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Restore(bytes.NewReader(snapshot.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if err := stateholder.Restore(bytes.NewReader(snapshot.Bytes()[1:])); err == nil {
+		t.Fatal("expected ErrorBadSnapshot, no error found")
+	} else if _, ok := err.(*ErrorBadSnapshot); !ok {
+		t.Fatalf("expected ErrorBadSnapshot, [%v] error found", err)
+	}
+}
+
+func TestExportImportJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.ImportJSON(strings.NewReader(`{"bytes":"48454c4c4f","uint64":1024}`)); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := stateholder.Get("bytes"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Compare(value, testBytes) != 0 {
+		t.Fatalf("bytes must be a %q, %v found", testBytes, value)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	buffer := new(bytes.Buffer)
+	if err := stateholder.ExportJSON(buffer); err != nil {
+		t.Fatal(err)
+	}
+	if value := buffer.String(); value != `{"bytes":"48454c4c4f","uint64":1024}` {
+		t.Fatalf("unexpected JSON %s found", value)
+	}
+	if err := stateholder.ImportJSON(strings.NewReader(`{"uint64":0,"bytes":"48"}`)); err == nil {
+		t.Fatal("expected ErrorIncompatibleSize, no error found")
+	} else if _, ok := err.(*ErrorIncompatibleSize); !ok {
+		t.Fatalf("expected ErrorIncompatibleSize, [%v] error found", err)
+	}
+	if value, err := stateholder.GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	// Variables can not be unpublished, so name must be unique when test is repeated.
+	name := "stateholder"
+	for i := 1; expvar.Get(name) != nil; i++ {
+		name = fmt.Sprintf("stateholder%d", i)
+	}
+	PublishExpvar(name, stateholder)
+	if value := expvar.Get(name).String(); value != `"stateholder: file detached"` {
+		t.Fatalf("unexpected expvar %s found", value)
+	}
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value := expvar.Get(name).String(); value != `{"bytes":"0000000000","uint64":0}` {
+		t.Fatalf("unexpected expvar %s found", value)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	changes, cancel, err := stateholder.Watch("uint64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	batches, cancelAll, err := stateholder.WatchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancelAll()
+	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if change := <-changes; change.String() != "uint64: 0 -> 1024" {
+		t.Fatalf("unexpected change %s found", change)
+	}
+	if batch := <-batches; len(batch) != 1 {
+		t.Fatalf("expected 1 change, %d found", len(batch))
+	}
+	tx, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set("bytes", testBytes); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tx.IncUint64("uint64", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if change := <-changes; change.String() != "uint64: 1024 -> 1025" {
+		t.Fatalf("unexpected change %s found", change)
+	}
+	if batch := <-batches; len(batch) != 2 {
+		t.Fatalf("expected 2 changes, %d found", len(batch))
+	}
+}
+
+func TestWaitForChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	seq, err := a.Sequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.WaitForChange(ctx, seq); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, [%v] error found", err)
+	}
+	if err := b.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := a.WaitForChange(context.Background(), seq); err != nil {
+		t.Fatal(err)
+	} else if value != seq+2 {
+		t.Fatalf("sequence must be a %d, %d found", seq+2, value)
+	}
+}
+
+func TestWaitForChangeAfterInterruptedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	seq, err := a.Sequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.setSequence(seq + 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if value, err := a.WaitForChange(ctx, seq); err != nil {
+		t.Fatal(err)
+	} else if value != seq+4 {
+		t.Fatalf("sequence must be a %d, %d found", seq+4, value)
+	}
+}
+
+func TestBadFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int64{info.Size() - 1, info.Size() + 1} {
+		if err := os.Truncate(path, size); err != nil {
+			t.Fatal(err)
+		}
+		stateholder := testStateholder()
+		if _, err := stateholder.Attach(path, nil); err == nil {
+			t.Fatal("expected ErrorBadFile, nil error found")
+		} else if _, ok := err.(*ErrorBadFile); !ok {
+			t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+		}
+		stateholder.Close()
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	if _, err := stateholder.AttachWithOptions(path, &Options{ReadOnly: true}); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, [%v] error found", err)
+	}
+	if _, err := stateholder.Attach(path, nil); err != nil {
 		t.Fatal(err)
 	}
 	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
 		t.Fatal(err)
 	}
+	seq, err := stateholder.Sequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.setSequence(seq + 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, factory := range []BackendFactory{FileBackend, MemoryBackend, MmapBackend} {
+		stateholder := testStateholder()
+		if _, err := stateholder.AttachWithOptions(path, &Options{Backend: factory, ReadOnly: true}); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := stateholder.GetUint64("uint64"); err != nil {
+			t.Fatal(err)
+		} else if value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		if err := stateholder.SetUint64("uint64", 0); err == nil {
+			t.Fatal("expected ErrorReadOnly, nil error found")
+		} else if _, ok := err.(*ErrorReadOnly); !ok {
+			t.Fatalf("expected ErrorReadOnly, [%v] error found", err)
+		}
+		if err := stateholder.DefineLive("live", KindUint64, 8); err == nil {
+			t.Fatal("expected ErrorReadOnly, nil error found")
+		} else if _, ok := err.(*ErrorReadOnly); !ok {
+			t.Fatalf("expected ErrorReadOnly, [%v] error found", err)
+		}
+		if err := stateholder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if current, err := ioutil.ReadFile(path); err != nil {
+			t.Fatal(err)
+		} else if bytes.Compare(current, data) != 0 {
+			t.Fatal("file must be left intact")
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	for _, buffered := range []bool{false, true} {
+		for _, format := range []struct {
+			version byte
+			header  []byte
+		}{{1, nil}, {2, []byte{5, 0, 0, 0, 0, 0, 0, 0}}} {
+			path := filepath.Join(t.TempDir(), "test.mem")
+			// Legacy default signature is followed by header and values without versions.
+			legacy := []byte{'M', 'E', 'M', format.version, 0, 0, byte(KindUint64), 8, 0, byte(KindBytes), 3, 0}
+			legacy = append(legacy, format.header...)
+			legacy = append(legacy, 42, 0, 0, 0, 0, 0, 0, 0, 'a', 'b', 'c')
+			if err := ioutil.WriteFile(path, legacy, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if info, err := ReadFileInfo(path); err != nil {
+				t.Fatal(err)
+			} else if len(info.Entries) != 2 || info.Entries[1].Offset != 8 {
+				t.Fatalf("legacy entries must be read, %v found", info.Entries)
+			}
+			stateholder := NewStateholder()
+			stateholder.DefineUint64("uint64")
+			stateholder.Define("bytes", 3)
+			if _, err := stateholder.AttachWithOptions(path, &Options{DoubleBuffered: buffered, ReadOnly: true}); err == nil {
+				t.Fatal("expected ErrorBadFile, nil error found")
+			} else if _, ok := err.(*ErrorBadFile); !ok {
+				t.Fatalf("expected ErrorBadFile, [%v] error found", err)
+			}
+			if init, err := stateholder.AttachWithOptions(path, &Options{DoubleBuffered: buffered}); err != nil {
+				t.Fatal(err)
+			} else if init {
+				t.Fatal("upgraded file must not be created")
+			}
+			if value, err := stateholder.GetUint64("uint64"); err != nil {
+				t.Fatal(err)
+			} else if value != 42 {
+				t.Fatalf("uint64 must be a %d, %d found", 42, value)
+			}
+			if value, version, err := stateholder.GetWithVersion("bytes"); err != nil {
+				t.Fatal(err)
+			} else if string(value) != "abc" {
+				t.Fatalf("bytes must be a %q, %q found", "abc", value)
+			} else if version != 0 {
+				t.Fatalf("version must be a %d, %d found", 0, version)
+			}
+			if seq, err := stateholder.Sequence(); err != nil {
+				t.Fatal(err)
+			} else if format.header != nil && seq != 6 {
+				t.Fatalf("sequence must be a %d, %d found", 6, seq)
+			}
+			if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+				t.Fatal(err)
+			}
+			if err := stateholder.Close(); err != nil {
+				t.Fatal(err)
+			}
+			info, err := ReadFileInfo(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Version != "3.0.0" || info.DoubleBuffered != buffered {
+				t.Fatalf("file must be upgraded, %s %s found", info.Magic, info.Version)
+			}
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := stateholder.AttachContext(ctx, path, nil); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, [%v] error found", err)
+	}
+	if _, err := stateholder.AttachContext(context.Background(), path, nil); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	stateholder.mutex.Lock()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tx.CommitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, [%v] error found", err)
+	}
+	if err := stateholder.SyncContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, [%v] error found", err)
+	}
+	stateholder.mutex.Unlock()
+	if err := tx.CommitContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SyncContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 	if value, err := stateholder.GetUint64("uint64"); err != nil {
 		t.Fatal(err)
 	} else if value != testUint64 {
 		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
 	}
-	if err := stateholder.Commit(); err != nil {
+}
+
+func TestContextFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(path, nil); err != nil {
 		t.Fatal(err)
 	}
-	if value, err := stateholder.GetUint64("uint64"); err != nil {
+	tx, err := b.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.lock(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tx.CommitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, [%v] error found", err)
+	}
+	c := testStateholder()
+	defer c.Close()
+	if _, err := c.AttachContext(ctx, path, nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, [%v] error found", err)
+	}
+	a.unlock()
+	if err := tx.CommitContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AttachContext(context.Background(), path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := c.GetUint64("uint64"); err != nil {
 		t.Fatal(err)
 	} else if value != testUint64 {
 		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
 	}
 }
 
-func TestTransactionRollback(t *testing.T) {
-	if err := clearStateholder(); err != nil {
+func TestContextReaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if _, err := stateholder.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				stateholder.mutex.RLock()
+				time.Sleep(time.Millisecond)
+				stateholder.mutex.RUnlock()
+			}
+		}()
+	}
+	tx, err := stateholder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tx.CommitContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterruptedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	seq, err := a.Sequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.setSequence(seq + 1); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	values, err := a.ReadConsistent("uint64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("consistent read must not take %v", elapsed)
+	}
+	if value := binary.LittleEndian.Uint64(values[0]); value != testUint64 {
+		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+	}
+	if value, err := a.Sequence(); err != nil {
+		t.Fatal(err)
+	} else if value != seq+2 {
+		t.Fatalf("sequence must be a %d, %d found", seq+2, value)
+	}
+	if err := a.setSequence(seq + 3); err != nil {
+		t.Fatal(err)
+	}
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := b.Sequence(); err != nil {
+		t.Fatal(err)
+	} else if value != seq+4 {
+		t.Fatalf("sequence must be a %d, %d found", seq+4, value)
+	}
+	if err := a.setSequence(seq + 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := a.Sequence(); err != nil {
+		t.Fatal(err)
+	} else if value != seq+8 {
+		t.Fatalf("sequence must be a %d, %d found", seq+8, value)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	writers := make([]*Stateholder, 2)
+	for i := range writers {
+		writers[i] = testStateholder()
+		defer writers[i].Close()
+		if _, err := writers[i].Attach(path, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const n = 20000
+	errs := make(chan error, len(writers))
+	for _, writer := range writers {
+		go func(writer *Stateholder) {
+			for i := 0; i < n; i++ {
+				if _, _, err := writer.IncUint64("uint64", 1); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(writer)
+	}
+	for range writers {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, err := writers[0].GetUint64("uint64"); err != nil {
 		t.Fatal(err)
+	} else if value != n*uint64(len(writers)) {
+		t.Fatalf("uint64 must be a %d, %d found", n*len(writers), value)
 	}
+	if seq, err := writers[1].Sequence(); err != nil {
+		t.Fatal(err)
+	} else if seq != 2*n*uint64(len(writers)) {
+		t.Fatalf("sequence must be a %d, %d found", 2*n*len(writers), seq)
+	}
+}
+
+func TestReadConsistent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
 	stateholder := testStateholder()
 	defer stateholder.Close()
-	if _, err := stateholder.Attach(testPath, nil); err != nil {
+	if _, err := stateholder.Attach(path, nil); err != nil {
 		t.Fatal(err)
 	}
-	if err := stateholder.Begin(); err != nil {
+	if err := stateholder.Update(func(tx *Tx) error {
+		if err := tx.Set("bytes", testBytes); err != nil {
+			return err
+		}
+		return tx.SetUint64("uint64", testUint64)
+	}); err != nil {
 		t.Fatal(err)
 	}
-	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+	if seq, err := stateholder.Sequence(); err != nil {
 		t.Fatal(err)
+	} else if seq%2 != 0 {
+		t.Fatalf("sequence must be even, %d found", seq)
 	}
-	if value, err := stateholder.GetUint64("uint64"); err != nil {
+	values, err := stateholder.ReadConsistent("bytes", "uint64")
+	if err != nil {
 		t.Fatal(err)
-	} else if value != testUint64 {
+	}
+	if bytes.Compare(values[0], testBytes) != 0 {
+		t.Fatalf("bytes must be a %v, %v found", testBytes, values[0])
+	}
+	if value := binary.LittleEndian.Uint64(values[1]); value != testUint64 {
 		t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
 	}
-	if err := stateholder.Rollback(); err != nil {
+	if _, err := stateholder.ReadConsistent("bytes", "undefined"); err == nil {
+		t.Fatal("expected ErrorUndefined, no error found")
+	} else if _, ok := err.(*ErrorUndefined); !ok {
+		t.Fatalf("expected ErrorUndefined, [%v] error found", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.mem")
+	otherPath := filepath.Join(dir, "test-diff.mem")
+	a := testStateholder()
+	defer a.Close()
+	if _, err := a.Attach(path, nil); err != nil {
 		t.Fatal(err)
 	}
-	if value, err := stateholder.GetUint64("uint64"); err != nil {
+	b := testStateholder()
+	defer b.Close()
+	if _, err := b.Attach(otherPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, %d found", len(changes))
+	}
+	if value := changes[0].String(); value != "uint64: 0 -> 1024" {
+		t.Fatalf("unexpected change %s found", value)
+	}
+}
+
+func TestDiffConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	sh := []*Stateholder{testStateholder(), testStateholder()}
+	for i, path := range []string{filepath.Join(dir, "a.mem"), filepath.Join(dir, "b.mem")} {
+		defer sh[i].Close()
+		if _, err := sh[i].Attach(path, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const n = 1000
+	errs := make(chan error, 4)
+	for i := range sh {
+		a, b := sh[i], sh[1-i]
+		go func() {
+			for j := 0; j < n; j++ {
+				if _, err := Diff(a, b); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+		go func() {
+			for j := 0; j < n; j++ {
+				if _, _, err := a.IncUint64("uint64", 1); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	timeout := time.After(10 * time.Second)
+	for i := 0; i < cap(errs); i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			t.Fatal("diff must not deadlock")
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if keys := stateholder.Keys(); len(keys) != 2 || keys[0] != "bytes" || keys[1] != "uint64" {
+		t.Fatalf("keys must be a %v, %v found", []string{"bytes", "uint64"}, keys)
+	}
+	if info, err := stateholder.Describe("uint64"); err != nil {
+		t.Fatal(err)
+	} else if info.Kind != KindUint64 || info.Size != 8 || info.Offset != 21 {
+		t.Fatalf("unexpected uint64 %+v found", info)
+	}
+	if size := stateholder.Size(); size != 29 {
+		t.Fatalf("size must be a %d, %d found", 29, size)
+	}
+	if _, err := stateholder.Describe("byte"); err == nil {
+		t.Fatal("expected ErrorUndefined, no error found")
+	} else if _, ok := err.(*ErrorUndefined); !ok {
+		t.Fatalf("expected ErrorUndefined, [%v] error found", err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	stateholder := testStateholder()
+	defer stateholder.Close()
+	if err := stateholder.Range(func(string, EntryInfo, []byte) bool { return true }); err == nil {
+		t.Fatal("expected ErrorDetached, nil error found")
+	} else if _, ok := err.(*ErrorDetached); !ok {
+		t.Fatalf("expected ErrorDetached, [%v] error found", err)
+	}
+	if _, err := stateholder.Attach(filepath.Join(t.TempDir(), "test.mem"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.Set("bytes", testBytes); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateholder.SetUint64("uint64", testUint64); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	err := stateholder.Range(func(key string, info EntryInfo, value []byte) bool {
+		keys = append(keys, key)
+		if key == "bytes" {
+			if bytes.Compare(value, testBytes) != 0 {
+				t.Fatalf("bytes must be a %v, %v found", testBytes, value)
+			}
+			// Values are read before the first call, so write is not visible.
+			if err := stateholder.SetUint64("uint64", testUint64+1); err != nil {
+				t.Fatal(err)
+			}
+			return true
+		}
+		if info.Kind != KindUint64 || info.Size != 8 {
+			t.Fatalf("unexpected uint64 %+v found", info)
+		}
+		if value := binary.LittleEndian.Uint64(value); value != testUint64 {
+			t.Fatalf("uint64 must be a %d, %d found", testUint64, value)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "bytes" || keys[1] != "uint64" {
+		t.Fatalf("keys must be a %v, %v found", []string{"bytes", "uint64"}, keys)
+	}
+	keys = nil
+	if err := stateholder.Range(func(key string, info EntryInfo, value []byte) bool {
+		keys = append(keys, key)
+		return false
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "bytes" {
+		t.Fatalf("keys must be a %v, %v found", []string{"bytes"}, keys)
+	}
+	if err := stateholder.DefineLive("uint32", KindUint32, 4); err != nil {
 		t.Fatal(err)
-	} else if value != emptyUint64 {
-		t.Fatalf("uint64 must be a %d, %d found", emptyUint64, value)
+	}
+	if size := stateholder.Size(); size != 41 {
+		t.Fatalf("size must be a %d, %d found", 41, size)
 	}
 }
 
@@ -223,3 +1599,72 @@ func BenchmarkNoSync(b *testing.B) {
 		}
 	}
 }
+
+func TestConcurrentVersionedWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mem")
+	writers := make([]*Stateholder, 2)
+	for i := range writers {
+		writers[i] = testStateholder()
+		defer writers[i].Close()
+		if _, err := writers[i].Attach(path, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const n = 5000
+	errs := make(chan error, 2*len(writers))
+	for _, writer := range writers {
+		go func(writer *Stateholder) {
+			for i := 0; i < n; {
+				value, version, err := writer.GetWithVersion("bytes")
+				if err != nil {
+					errs <- err
+					return
+				}
+				binary.LittleEndian.PutUint32(value, binary.LittleEndian.Uint32(value)+1)
+				if err := writer.SetIfVersion("bytes", value, version); err == nil {
+					i++
+				} else if _, ok := err.(*ErrorConflict); !ok {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(writer)
+		go func(writer *Stateholder) {
+			for i := 0; i < n; {
+				tx, err := writer.Begin()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, _, err := tx.IncUint64("uint64", 1); err != nil {
+					tx.Rollback()
+					errs <- err
+					return
+				}
+				if err := tx.Commit(); err == nil {
+					i++
+				} else if _, ok := err.(*ErrorConflict); !ok {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(writer)
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, err := writers[0].Get("bytes"); err != nil {
+		t.Fatal(err)
+	} else if counter := binary.LittleEndian.Uint32(value); counter != n*uint32(len(writers)) {
+		t.Fatalf("counter must be a %d, %d found", n*len(writers), counter)
+	}
+	if value, err := writers[1].GetUint64("uint64"); err != nil {
+		t.Fatal(err)
+	} else if value != n*uint64(len(writers)) {
+		t.Fatalf("uint64 must be a %d, %d found", n*len(writers), value)
+	}
+}
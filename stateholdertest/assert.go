@@ -0,0 +1,50 @@
+package stateholdertest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+// Fail test unless entry value formatted according to its kind equals to expected one.
+func AssertValue(tb testing.TB, sh *stateholder.Stateholder, key, expected string) {
+	tb.Helper()
+	value, err := sh.GetString(key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if value != expected {
+		tb.Fatalf("%s must be a %s, %s found", key, expected, value)
+	}
+}
+
+// Fail test unless error has the same type as expected one.
+func AssertError(tb testing.TB, err error, expected error) {
+	tb.Helper()
+	if err == nil {
+		tb.Fatalf("expected %T, no error found", expected)
+	}
+	if reflect.TypeOf(err) != reflect.TypeOf(expected) {
+		tb.Fatalf("expected %T, [%v] error found", expected, err)
+	}
+}
+
+// Take snapshot of committed data.
+func Snapshot(tb testing.TB, sh *stateholder.Stateholder) []byte {
+	tb.Helper()
+	buffer := new(bytes.Buffer)
+	if err := sh.Snapshot(buffer); err != nil {
+		tb.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// Fail test unless committed data equals to snapshot.
+func AssertSnapshot(tb testing.TB, sh *stateholder.Stateholder, snapshot []byte) {
+	tb.Helper()
+	if value := Snapshot(tb, sh); bytes.Compare(value, snapshot) != 0 {
+		tb.Fatalf("snapshot must be a %v, %v found", snapshot, value)
+	}
+}
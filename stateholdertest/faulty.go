@@ -0,0 +1,166 @@
+// Package stateholdertest provides utilities for testing code which uses stateholder.
+package stateholdertest
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/alexeymaximov/stateholder"
+	"github.com/alexeymaximov/syspack"
+)
+
+// Fault.
+type Fault byte
+
+// Available faults.
+const (
+	// No fault.
+	FaultNone Fault = iota
+
+	// Write only half of buffer.
+	FaultShortWrite
+
+	// Return ErrInjected on write and sync.
+	FaultError
+
+	// Stop applying writes while reporting success.
+	FaultCrash
+)
+
+// Error returned by faulty backend.
+var ErrInjected = errors.New("stateholdertest: injected fault")
+
+type FaultyBackend struct {
+	// Faulty backend settings shared by all opened backends.
+
+	// Backend factory.
+	factory stateholder.BackendFactory
+
+	// Mutex.
+	mutex sync.Mutex
+
+	// Fault.
+	fault Fault
+
+	// Writes left before fault.
+	left int
+
+	// Writes count.
+	writes int
+}
+
+type faultyBackend struct {
+	// Opened faulty backend.
+
+	// Settings.
+	faults *FaultyBackend
+
+	// Wrapped backend.
+	backend stateholder.Backend
+}
+
+// Make new faulty backend which wraps backends made by given factory.
+// Its Factory method must be used as backend factory on attach.
+func NewFaultyBackend(factory stateholder.BackendFactory) *FaultyBackend {
+	return &FaultyBackend{factory: factory}
+}
+
+// Open wrapped backend.
+// Every opened backend is subject to injected fault and resets writes count.
+func (backend *FaultyBackend) Factory(file *os.File, offset syspack.Offset, size syspack.Size) (stateholder.Backend, error) {
+	inner, err := backend.factory(file, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	backend.mutex.Lock()
+	backend.writes = 0
+	backend.mutex.Unlock()
+	return &faultyBackend{faults: backend, backend: inner}, nil
+}
+
+// Inject fault which occurs after given number of successful writes.
+// Fault persists until next injection.
+func (backend *FaultyBackend) Inject(fault Fault, after int) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	backend.fault = fault
+	backend.left = after
+}
+
+// Get number of writes made since backend was opened last time.
+func (backend *FaultyBackend) Writes() int {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	return backend.writes
+}
+
+// Get active fault.
+func (backend *FaultyBackend) active() Fault {
+	if backend.left > 0 {
+		return FaultNone
+	}
+	return backend.fault
+}
+
+// Read data at offset.
+func (backend *faultyBackend) ReadAt(buffer []byte, offset syspack.Offset) (int, error) {
+	return backend.backend.ReadAt(buffer, offset)
+}
+
+// Write data at offset.
+func (backend *faultyBackend) WriteAt(buffer []byte, offset syspack.Offset) (int, error) {
+	faults := backend.faults
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	faults.writes++
+	switch faults.active() {
+	case FaultShortWrite:
+		return backend.backend.WriteAt(buffer[:len(buffer)/2], offset)
+	case FaultError:
+		return 0, ErrInjected
+	case FaultCrash:
+		return len(buffer), nil
+	}
+	if faults.left > 0 {
+		faults.left--
+	}
+	return backend.backend.WriteAt(buffer, offset)
+}
+
+// Sync data.
+func (backend *faultyBackend) Sync() error {
+	faults := backend.faults
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	switch faults.active() {
+	case FaultError:
+		return ErrInjected
+	case FaultCrash:
+		return nil
+	}
+	return backend.backend.Sync()
+}
+
+// Sync data range.
+// Whole data is synced if wrapped backend is not able to sync range.
+func (backend *faultyBackend) SyncRange(offset syspack.Offset, size syspack.Size) error {
+	faults := backend.faults
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	switch faults.active() {
+	case FaultError:
+		return ErrInjected
+	case FaultCrash:
+		return nil
+	}
+	if syncer, ok := backend.backend.(stateholder.RangeSyncer); ok {
+		return syncer.SyncRange(offset, size)
+	}
+	return backend.backend.Sync()
+}
+
+// Close backend.
+func (backend *faultyBackend) Close() error {
+	return backend.backend.Close()
+}
@@ -0,0 +1,184 @@
+package stateholdertest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexeymaximov/stateholder"
+)
+
+func testStateholder(t *testing.T, buffered bool) (*stateholder.Stateholder, *FaultyBackend) {
+	return testStateholderWithPolicy(t, buffered, stateholder.SyncNever)
+}
+
+func testStateholderWithPolicy(t *testing.T, buffered bool, policy stateholder.SyncPolicy) (*stateholder.Stateholder, *FaultyBackend) {
+	sh := stateholder.NewStateholder()
+	sh.DefineUint64("a")
+	sh.DefineUint64("b")
+	backend := NewFaultyBackend(stateholder.MemoryBackend)
+	if _, err := sh.AttachWithOptions(filepath.Join(t.TempDir(), "test-faulty.mem"), &stateholder.Options{Backend: backend.Factory, DoubleBuffered: buffered, Sync: policy}); err != nil {
+		t.Fatal(err)
+	}
+	return sh, backend
+}
+
+func TestShortWrite(t *testing.T) {
+	sh, backend := testStateholder(t, false)
+	defer sh.Close()
+	backend.Inject(FaultShortWrite, 1)
+	AssertError(t, sh.SetUint64("a", 1), &stateholder.ErrorCorruptedWrite{})
+}
+
+func TestPartialCommit(t *testing.T) {
+	sh, backend := testStateholder(t, false)
+	defer sh.Close()
+	tx, err := sh.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.SetUint64("a", 1)
+	tx.SetUint64("b", 2)
+	backend.Inject(FaultError, 3)
+	if err := tx.Commit(); err != ErrInjected {
+		t.Fatalf("expected ErrInjected, [%v] error found", err)
+	}
+	AssertValue(t, sh, "a", "1")
+	AssertValue(t, sh, "b", "0")
+}
+
+func TestAtomicCommit(t *testing.T) {
+	sh, backend := testStateholder(t, true)
+	defer sh.Close()
+	tx, err := sh.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.SetUint64("a", 1)
+	tx.SetUint64("b", 2)
+	backend.Inject(FaultError, 4)
+	if err := tx.Commit(); err != ErrInjected {
+		t.Fatalf("expected ErrInjected, [%v] error found", err)
+	}
+	AssertValue(t, sh, "a", "0")
+	AssertValue(t, sh, "b", "0")
+	backend.Inject(FaultNone, 0)
+	if err := sh.SetUint64("b", 2); err != nil {
+		t.Fatal(err)
+	}
+	AssertValue(t, sh, "b", "2")
+}
+
+func TestCrash(t *testing.T) {
+	sh, backend := testStateholder(t, false)
+	defer sh.Close()
+	snapshot := Snapshot(t, sh)
+	backend.Inject(FaultCrash, 0)
+	if err := sh.SetUint64("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	AssertSnapshot(t, sh, snapshot)
+	if writes := backend.Writes(); writes != 3 {
+		t.Fatalf("writes must be a %d, %d found", 3, writes)
+	}
+}
+
+func TestSyncOnCommit(t *testing.T) {
+	sh, backend := testStateholderWithPolicy(t, false, stateholder.SyncOnCommit)
+	defer sh.Close()
+	backend.Inject(FaultError, 4)
+	if err := sh.SetUint64("a", 1); err != ErrInjected {
+		t.Fatalf("expected ErrInjected, [%v] error found", err)
+	}
+	AssertValue(t, sh, "a", "1")
+}
+
+func TestBackgroundSync(t *testing.T) {
+	sh, backend := testStateholderWithPolicy(t, false, stateholder.SyncEvery(time.Millisecond))
+	defer sh.Close()
+	// Only dirty ranges are synced, so there must be some.
+	if err := sh.SetUint64("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	backend.Inject(FaultError, 0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if err := sh.SyncError(); err == ErrInjected {
+			break
+		} else if err != nil {
+			t.Fatalf("expected ErrInjected, [%v] error found", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected ErrInjected, no error found")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := sh.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedDetach(t *testing.T) {
+	sh, backend := testStateholderWithPolicy(t, false, stateholder.SyncEvery(time.Millisecond))
+	defer sh.Close()
+	// Only dirty ranges are synced, so there must be some.
+	if err := sh.SetUint64("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	backend.Inject(FaultError, 0)
+	if err := sh.Detach(); err != ErrInjected {
+		t.Fatalf("expected ErrInjected, [%v] error found", err)
+	}
+	sh.SyncError()
+	deadline := time.Now().Add(time.Second)
+	for sh.SyncError() != ErrInjected {
+		if time.Now().After(deadline) {
+			t.Fatal("background sync must keep running after failed detach")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	backend.Inject(FaultNone, 0)
+	if err := sh.Detach(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	sh := stateholder.NewStateholder()
+	defer sh.Close()
+	sh.DefineUint64("a")
+	path := filepath.Join(t.TempDir(), "test-faulty.mem")
+	backend := NewFaultyBackend(stateholder.MmapBackend)
+	options := &stateholder.Options{Backend: backend.Factory}
+	if _, err := sh.AttachWithOptions(path, options); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh.SetUint64("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sh.AttachWithOptions(path, options); err != nil {
+		t.Fatal(err)
+	}
+	if writes := backend.Writes(); writes != 0 {
+		t.Fatalf("writes must be a %d, %d found", 0, writes)
+	}
+	if err := sh.SetUint64("a", 2); err != nil {
+		t.Fatal(err)
+	}
+	backend.Inject(FaultError, 0)
+	if err := sh.Sync(); err != ErrInjected {
+		t.Fatalf("expected ErrInjected, [%v] error found", err)
+	}
+	backend.Inject(FaultNone, 0)
+	if err := sh.DefineLive("b", stateholder.KindUint64, 8); err != nil {
+		t.Fatal(err)
+	}
+	AssertValue(t, sh, "a", "2")
+	AssertValue(t, sh, "b", "0")
+}
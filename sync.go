@@ -0,0 +1,101 @@
+package stateholder
+
+import "time"
+
+// Durability policy.
+type SyncPolicy struct {
+	// Whether to sync after every write and commit.
+	commit bool
+
+	// Interval of background sync.
+	interval time.Duration
+
+	// Number of writes and commits between syncs.
+	writes int
+}
+
+// Never sync data automatically, it is default policy.
+var SyncNever = SyncPolicy{}
+
+// Sync data after every write and commit.
+var SyncOnCommit = SyncPolicy{commit: true}
+
+// Sync data periodically in background.
+func SyncEvery(interval time.Duration) SyncPolicy {
+	return SyncPolicy{interval: interval}
+}
+
+// Sync data after every n writes and commits.
+func SyncEveryNWrites(n int) SyncPolicy {
+	return SyncPolicy{writes: n}
+}
+
+// Sync data according to policy after write or commit.
+func (sh *Stateholder) syncOnWrite() error {
+	if sh.policy.commit {
+		return sh.sync()
+	}
+	if sh.policy.writes > 0 {
+		if sh.pending++; sh.pending >= sh.policy.writes {
+			sh.pending = 0
+			return sh.sync()
+		}
+	}
+	return nil
+}
+
+// Start background sync if required by policy.
+// Caller must hold stateholder lock.
+func (sh *Stateholder) startSync() {
+	if sh.policy.interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	sh.stopSync = stop
+	go sh.syncLoop(sh.policy.interval, stop)
+}
+
+// Stop background sync.
+// Caller must hold stateholder lock.
+func (sh *Stateholder) stopSyncLoop() {
+	if sh.stopSync != nil {
+		close(sh.stopSync)
+		sh.stopSync = nil
+	}
+}
+
+// Sync data periodically until stopped.
+func (sh *Stateholder) syncLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		sh.mutex.RLock()
+		select {
+		case <-stop:
+			sh.mutex.RUnlock()
+			return
+		default:
+		}
+		err := sh.sync()
+		sh.mutex.RUnlock()
+		if err != nil {
+			sh.syncMutex.Lock()
+			sh.syncErr = err
+			sh.syncMutex.Unlock()
+		}
+	}
+}
+
+// Get and clear last error occurred in background sync.
+func (sh *Stateholder) SyncError() error {
+	sh.syncMutex.Lock()
+	defer sh.syncMutex.Unlock()
+	err := sh.syncErr
+	sh.syncErr = nil
+	return err
+}
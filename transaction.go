@@ -1,79 +1,92 @@
 package stateholder
 
+import (
+	"context"
+	"sync/atomic"
+)
+
 // Begin transaction.
-func (sh *Stateholder) Begin() error {
+// Transaction has its own write set which is applied on commit,
+// it must not be used by multiple goroutines concurrently.
+func (sh *Stateholder) Begin() (*Tx, error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
 	if sh.index == nil {
-		return &ErrorClosed{}
-	}
-	if sh.mapping == nil {
-		return &ErrorDetached{}
+		return nil, &ErrorClosed{}
 	}
-	if sh.transaction {
-		return &ErrorTransactionAlreadyStarted{}
+	if sh.backend == nil {
+		return nil, &ErrorDetached{}
 	}
-	sh.transaction = true
-	return nil
+	atomic.AddInt64(&sh.transactions, 1)
+	return &Tx{
+		sh:       sh,
+		layers:   []*layer{{writes: make(map[*entry][]byte)}},
+		versions: make(map[*entry]uint64),
+	}, nil
+}
+
+// Mark transaction finished.
+func (tx *Tx) finish() {
+	tx.done = true
+	atomic.AddInt64(&tx.sh.transactions, -1)
 }
 
 // Rollback transaction.
-func (sh *Stateholder) Rollback() error {
-	if sh.index == nil {
-		return &ErrorClosed{}
-	}
-	if sh.mapping == nil {
-		return &ErrorDetached{}
-	}
-	if !sh.transaction {
-		return &ErrorTransactionNotStarted{}
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return &ErrorTransactionFinished{}
 	}
-	for _, entry := range sh.entries {
-		entry.buffer = nil
-	}
-	sh.transaction = false
+	tx.finish()
+	tx.layers = nil
+	tx.versions = nil
 	return nil
 }
 
 // Commit transaction.
-func (sh *Stateholder) commit() error {
-	if !sh.transaction {
-		return &ErrorTransactionNotStarted{}
-	}
-	// TODO: Add full commit buffer.
-	for _, entry := range sh.entries {
-		if entry.buffer != nil {
-			if n, err := sh.mapping.WriteAt(entry.buffer, entry.offset); err != nil {
-				return err
-			} else if n != int(entry.size) {
-				return &ErrorCorruptedWrite{Real: n, Expected: int(entry.size)}
-			}
-			entry.buffer = nil
-		}
-	}
-	sh.transaction = false
-	return nil
+// It fails with ErrorConflict if any entry read or written within transaction
+// was modified by someone else, including other processes, since it was accessed first time.
+// Versions are checked and writes are applied under file lock, so they are atomic across processes.
+func (tx *Tx) Commit() error {
+	return tx.CommitContext(context.Background())
 }
 
-// Commit transaction.
-func (sh *Stateholder) Commit() error {
+// Commit transaction or fail when context is done before stateholder lock is acquired.
+// Transaction remains active in that case.
+func (tx *Tx) CommitContext(ctx context.Context) error {
+	if tx.done {
+		return &ErrorTransactionFinished{}
+	}
+	sh := tx.sh
+	if err := sh.lockContext(ctx); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	tx.finish()
 	if sh.index == nil {
 		return &ErrorClosed{}
 	}
-	if sh.mapping == nil {
+	if sh.backend == nil {
 		return &ErrorDetached{}
 	}
-	return sh.commit()
+	for entry, observed := range tx.versions {
+		version, err := sh.version(entry)
+		if err != nil {
+			return err
+		}
+		if version != observed {
+			return &ErrorConflict{Key: entry.key}
+		}
+	}
+	// TODO: Add full commit buffer.
+	changes, err := sh.apply(tx.writes())
+	sh.watchers.notify(changes)
+	return err
 }
 
 // Commit transaction and sync data.
-func (sh *Stateholder) Persist() error {
-	if sh.index == nil {
-		return &ErrorClosed{}
-	}
-	if sh.mapping == nil {
-		return &ErrorDetached{}
-	}
-	if err := sh.commit(); err != nil {
+func (tx *Tx) Persist() error {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
-	return sh.mapping.Sync()
+	return tx.sh.Sync()
 }
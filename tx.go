@@ -0,0 +1,197 @@
+package stateholder
+
+// Entry accessor.
+type accessor interface {
+	// Lookup entry.
+	lookup(key string) (*entry, error)
+
+	// Read entry.
+	read(entry *entry) ([]byte, error)
+
+	// Write entry.
+	write(entry *entry, value []byte) error
+}
+
+type layer struct {
+	// Transaction layer.
+
+	// Savepoint name.
+	name string
+
+	// Written values.
+	writes map[*entry][]byte
+}
+
+type Tx struct {
+	// Transaction.
+
+	// Stateholder.
+	sh *Stateholder
+
+	// Layers, each savepoint starts new one.
+	layers []*layer
+
+	// Entry versions observed on first access.
+	versions map[*entry]uint64
+
+	// Read-only mode.
+	readOnly bool
+
+	// Stateholder lock is held for whole transaction.
+	locked bool
+
+	// Finished.
+	done bool
+}
+
+// Lookup entry.
+func (tx *Tx) lookup(key string) (*entry, error) {
+	if tx.done {
+		return nil, &ErrorTransactionFinished{}
+	}
+	if !tx.locked {
+		tx.sh.mutex.RLock()
+		defer tx.sh.mutex.RUnlock()
+	}
+	return tx.sh.lookup(key)
+}
+
+// Get value written within transaction.
+func (tx *Tx) written(entry *entry) ([]byte, bool) {
+	for i := len(tx.layers) - 1; i >= 0; i-- {
+		if buffer, ok := tx.layers[i].writes[entry]; ok {
+			return buffer, true
+		}
+	}
+	return nil, false
+}
+
+// Merge layers into single write set.
+func (tx *Tx) writes() map[*entry][]byte {
+	writes := make(map[*entry][]byte)
+	for _, layer := range tx.layers {
+		for entry, buffer := range layer.writes {
+			writes[entry] = buffer
+		}
+	}
+	return writes
+}
+
+// Read entry.
+func (tx *Tx) read(entry *entry) ([]byte, error) {
+	if buffer, ok := tx.written(entry); ok {
+		value := make([]byte, entry.size)
+		copy(value, buffer)
+		return value, nil
+	}
+	if !tx.locked {
+		tx.sh.mutex.RLock()
+		defer tx.sh.mutex.RUnlock()
+	}
+	if tx.sh.backend == nil {
+		return nil, &ErrorDetached{}
+	}
+	if err := tx.observe(entry); err != nil {
+		return nil, err
+	}
+	return tx.sh.read(entry)
+}
+
+// Write entry.
+func (tx *Tx) write(entry *entry, value []byte) error {
+	if tx.readOnly {
+		return &ErrorReadOnly{}
+	}
+	tx.sh.mutex.RLock()
+	err := tx.observe(entry)
+	tx.sh.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	top := tx.layers[len(tx.layers)-1]
+	buffer, ok := top.writes[entry]
+	if !ok {
+		buffer = make([]byte, entry.size)
+		top.writes[entry] = buffer
+	}
+	copy(buffer, value)
+	return nil
+}
+
+// Set savepoint.
+func (tx *Tx) Savepoint(name string) error {
+	if tx.done {
+		return &ErrorTransactionFinished{}
+	}
+	tx.layers = append(tx.layers, &layer{name: name, writes: make(map[*entry][]byte)})
+	return nil
+}
+
+// Rollback writes made since the latest savepoint with given name.
+// Savepoint itself is kept, so it may be used again.
+func (tx *Tx) RollbackTo(name string) error {
+	if tx.done {
+		return &ErrorTransactionFinished{}
+	}
+	for i := len(tx.layers) - 1; i > 0; i-- {
+		if tx.layers[i].name == name {
+			tx.layers = tx.layers[:i+1]
+			tx.layers[i].writes = make(map[*entry][]byte)
+			return nil
+		}
+	}
+	return &ErrorUndefinedSavepoint{Name: name}
+}
+
+// Remember entry version on first access.
+func (tx *Tx) observe(entry *entry) error {
+	if tx.versions == nil {
+		return nil
+	}
+	if _, ok := tx.versions[entry]; ok {
+		return nil
+	}
+	if tx.sh.backend == nil {
+		return &ErrorDetached{}
+	}
+	version, err := tx.sh.version(entry)
+	if err != nil {
+		return err
+	}
+	tx.versions[entry] = version
+	return nil
+}
+
+// Run function within transaction.
+// Transaction is committed if function returns nil
+// and rolled back if it returns error or panics.
+func (sh *Stateholder) Update(fn func(tx *Tx) error) error {
+	tx, err := sh.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Run function within read-only transaction.
+// Writes are blocked until function returns, so it must not call stateholder methods.
+// Function is called again if data was modified by other process meanwhile.
+func (sh *Stateholder) View(fn func(tx *Tx) error) error {
+	return sh.consistently(func() error {
+		tx := &Tx{sh: sh, readOnly: true, locked: true}
+		defer func() {
+			tx.done = true
+		}()
+		return fn(tx)
+	})
+}
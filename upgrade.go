@@ -0,0 +1,90 @@
+package stateholder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+
+	"github.com/alexeymaximov/syspack"
+)
+
+type legacyFormat struct {
+	// Layout of earlier format with default signature.
+
+	// Header size.
+	header syspack.Size
+
+	// Entry version size.
+	version syspack.Size
+}
+
+// Legacy formats by major version, they have single copy of data only.
+// Format 1.0.0 has neither header nor entry versions, format 2.0.0 has no entry versions.
+var legacyFormats = map[byte]legacyFormat{
+	1: {header: 0, version: 0},
+	2: {header: headerSize, version: 0},
+}
+
+// Get legacy format of default signature header.
+func legacy(header []byte) (legacyFormat, bool) {
+	if header[0] != 'M' || header[1] != 'E' || header[2] != 'M' || header[4] != 0 || header[5] != 0 {
+		return legacyFormat{}, false
+	}
+	format, ok := legacyFormats[header[3]]
+	return format, ok
+}
+
+// Upgrade file of legacy format to current one and return true if it was upgraded.
+// File is rewritten in place with values of entries, their versions start from zero
+// and change sequence number is advanced, so readers notice the change.
+// Upgrade is not atomic, so file may be left bad in case of crash.
+// Caller must hold file lock.
+func (sh *Stateholder) upgradeFile(file *os.File, fileSize int64, buffered bool) (bool, error) {
+	sign := sh.defaultSign(buffered)
+	if fileSize < int64(len(sign)) {
+		return false, nil
+	}
+	old := make([]byte, len(sign))
+	if _, err := file.ReadAt(old, 0); err != nil {
+		return false, err
+	}
+	format, ok := legacy(old)
+	if !ok || bytes.Compare(old[signHeaderSize:], sign[signHeaderSize:]) != 0 {
+		return false, nil
+	}
+	dataSize := sh.size - syspack.Size(len(sh.entries))*(versionSize-format.version)
+	if fileSize != int64(len(sign))+int64(format.header+dataSize) {
+		return false, nil
+	}
+	old = make([]byte, fileSize)
+	if n, err := file.ReadAt(old, 0); err != nil {
+		return false, err
+	} else if n != len(old) {
+		return false, &ErrorCorruptedRead{Real: n, Expected: len(old)}
+	}
+	region := sh.initialRegion(buffered)
+	if format.header > 0 {
+		seq := binary.LittleEndian.Uint64(old[len(sign):])
+		binary.LittleEndian.PutUint64(region, seq+2-seq%2)
+	}
+	copies := 1
+	if buffered {
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		base := dataOffset(buffered, uint64(i), sh.size)
+		offset := syspack.Offset(len(sign)) + syspack.Offset(format.header)
+		for _, entry := range sh.entries {
+			offset += syspack.Offset(format.version)
+			copy(region[base+entry.offset:], old[offset:offset+syspack.Offset(entry.size)])
+			offset += syspack.Offset(entry.size)
+		}
+	}
+	if err := sh.prepareFile(file, sign, region); err != nil {
+		// Restore old layout, so file remains usable if it is possible.
+		file.WriteAt(old, 0)
+		file.Truncate(fileSize)
+		return false, err
+	}
+	return true, nil
+}
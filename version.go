@@ -0,0 +1,51 @@
+package stateholder
+
+// Get byte array and its version.
+// They are read consistently with writes made by other processes.
+func (sh *Stateholder) GetWithVersion(key string) ([]byte, uint64, error) {
+	var value []byte
+	var version uint64
+	err := sh.consistently(func() error {
+		entry, err := sh.lookup(key)
+		if err != nil {
+			return err
+		}
+		if entry.kind != KindBytes {
+			return &ErrorIncompatibleKind{Key: key, Kind: entry.kind, GivenKind: KindBytes}
+		}
+		if value, err = sh.read(entry); err != nil {
+			return err
+		}
+		version, err = sh.version(entry)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, version, nil
+}
+
+// Set byte array if its version equals to given one.
+// It fails with ErrorConflict if entry was written by someone else, including other processes.
+// Version is checked and value is written under file lock, so they are atomic across processes.
+func (sh *Stateholder) SetIfVersion(key string, value []byte, version uint64) error {
+	if err := sh.lock(); err != nil {
+		return err
+	}
+	defer sh.unlock()
+	entry, err := sh.lookup(key)
+	if err != nil {
+		return err
+	}
+	if entry.kind != KindBytes {
+		return &ErrorIncompatibleKind{Key: key, Kind: entry.kind, GivenKind: KindBytes}
+	}
+	current, err := sh.version(entry)
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return &ErrorConflict{Key: key}
+	}
+	return set(sh, key, KindBytes, value)
+}
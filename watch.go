@@ -0,0 +1,130 @@
+package stateholder
+
+import "sync"
+
+// Watch channel buffer size.
+const watchBuffer = 64
+
+type watchers struct {
+	// Watchers.
+
+	// Mutex.
+	mutex sync.Mutex
+
+	// Entry watchers by key.
+	keys map[string]map[chan Change]struct{}
+
+	// Watchers of all entries.
+	all map[chan []Change]struct{}
+}
+
+// Check whether there are any watchers.
+func (w *watchers) watched() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.keys) > 0 || len(w.all) > 0
+}
+
+// Notify watchers about changes.
+func (w *watchers) notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for ch := range w.all {
+		select {
+		case ch <- changes:
+		default:
+		}
+	}
+	for _, change := range changes {
+		for ch := range w.keys[change.Key] {
+			select {
+			case ch <- change:
+			default:
+			}
+		}
+	}
+}
+
+// Close all watch channels.
+func (w *watchers) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, channels := range w.keys {
+		for ch := range channels {
+			close(ch)
+		}
+	}
+	for ch := range w.all {
+		close(ch)
+	}
+	w.keys = nil
+	w.all = nil
+}
+
+// Watch entry changes.
+// Change is sent when entry is modified directly or by committed transaction.
+// Changes are dropped while channel buffer is full and their values must not be modified.
+// Cancel function stops watching and closes channel, channel is also closed on stateholder close.
+func (sh *Stateholder) Watch(key string) (<-chan Change, func(), error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return nil, nil, &ErrorClosed{}
+	}
+	if _, ok := sh.index[key]; !ok {
+		return nil, nil, &ErrorUndefined{Key: key}
+	}
+	w := &sh.watchers
+	ch := make(chan Change, watchBuffer)
+	w.mutex.Lock()
+	if w.keys == nil {
+		w.keys = make(map[string]map[chan Change]struct{})
+	}
+	if w.keys[key] == nil {
+		w.keys[key] = make(map[chan Change]struct{})
+	}
+	w.keys[key][ch] = struct{}{}
+	w.mutex.Unlock()
+	return ch, func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		if _, ok := w.keys[key][ch]; ok {
+			delete(w.keys[key], ch)
+			if len(w.keys[key]) == 0 {
+				delete(w.keys, key)
+			}
+			close(ch)
+		}
+	}, nil
+}
+
+// Watch changes of all entries.
+// Changes made by committed transaction are sent as single batch.
+// Batches are dropped while channel buffer is full and their values must not be modified.
+// Cancel function stops watching and closes channel, channel is also closed on stateholder close.
+func (sh *Stateholder) WatchAll() (<-chan []Change, func(), error) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	if sh.index == nil {
+		return nil, nil, &ErrorClosed{}
+	}
+	w := &sh.watchers
+	ch := make(chan []Change, watchBuffer)
+	w.mutex.Lock()
+	if w.all == nil {
+		w.all = make(map[chan []Change]struct{})
+	}
+	w.all[ch] = struct{}{}
+	w.mutex.Unlock()
+	return ch, func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		if _, ok := w.all[ch]; ok {
+			delete(w.all, ch)
+			close(ch)
+		}
+	}, nil
+}